@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"triple-s/pkg/kms"
+	"triple-s/pkg/server"
+)
+
+// runKMS запускает локальную службу ключей, к которой сервер подключается с -kms: triple-s kms [options]
+func runKMS(args []string) {
+	flags := flag.NewFlagSet("kms", flag.ExitOnError)
+	port := flags.String("port", "9200", "Port number")
+	keysPath := flags.String("keys", kms.LocalKeysFileName, "Path to the key file (KeyID,base64 key per line)")
+	flags.Parse(args)
+
+	if _, err := server.ValidatePort(*port); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	local, err := kms.LoadLocal(*keysPath)
+	if err != nil {
+		log.Fatalf("error loading KMS keys: %v", err)
+	}
+	keys, _ := local.ListKeys()
+	fmt.Printf("Serving %d KMS keys from %s on port %s\n", len(keys), *keysPath, *port)
+
+	if err := http.ListenAndServe(":"+*port, kms.Handler(local)); err != nil {
+		log.Fatalf("Failed to start KMS: %v\n", err)
+	}
+}
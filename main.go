@@ -6,13 +6,37 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
+	"triple-s/pkg/auth"
+	"triple-s/pkg/bucket"
+	"triple-s/pkg/kms"
+	"triple-s/pkg/object"
 	"triple-s/pkg/server"
+	"triple-s/pkg/sse"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "presign" {
+		runPresign(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "kms" {
+		runKMS(os.Args[2:])
+		return
+	}
+
 	port := flag.String("port", "8080", "Port number")
 	dir := flag.String("dir", "data", "Path to the directory")
+	authEnabled := flag.Bool("auth", false, "Require AWS Signature Version 4 authentication")
+	domain := flag.String("domain", "", "Base domain for virtual-hosted-style requests (<bucket>.<domain>)")
+	websiteDomain := flag.String("website-domain", "", "Domain for static website hosting (<bucket>.<website-domain>)")
+	websitePort := flag.String("website-port", "", "Port number of a separate static website endpoint")
+	masterKey := flag.String("master-key", "", "Path to the master key file for server-side encryption (default <dir>/master.key)")
+	kmsEndpoint := flag.String("kms", "", "URL of an external key management service for aws:kms (default: local keys in <dir>/kms.keys)")
+	rotateMasterKey := flag.Bool("rotate-master-key", false, "Rotate the master key and rewrap all data keys before starting")
+	lifecycleInterval := flag.Duration("lifecycle-interval", time.Hour, "How often lifecycle rules are applied (0 disables)")
 	help := flag.Bool("help", false, "Show this help message")
 	flag.Parse()
 
@@ -25,6 +49,11 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error: %v/n", err)
 	}
+	if *websitePort != "" {
+		if _, err := server.ValidatePort(*websitePort); err != nil {
+			log.Fatalf("Error: %v/n", err)
+		}
+	}
 
 	if _, err := os.Stat(*dir); os.IsNotExist(err) {
 		err = os.Mkdir(*dir, 0o755)
@@ -34,10 +63,87 @@ func main() {
 		fmt.Printf("Directoty %s created.\n", *dir)
 	}
 
+	// Объекты, записанные до перехода на хешированные имена файлов, переносятся на новое место
+	if err := object.MigrateObjectLayout(*dir); err != nil {
+		log.Fatalf("error migrating objects: %v", err)
+	}
+
+	// Мастер-ключи оборачивают ключи данных зашифрованных объектов; при первом запуске ключ создается
+	if *masterKey == "" {
+		*masterKey = filepath.Join(*dir, sse.MasterKeyFileName)
+	}
+	keyring, err := sse.LoadKeyring(*masterKey)
+	if err != nil {
+		log.Fatalf("error loading master key: %v", err)
+	}
+	object.SetKeyring(keyring)
+
+	// Ротация — операция администратора: она выполняется до запуска сервера, а не по API
+	if *rotateMasterKey {
+		keyID, rewrapped, err := object.RotateMasterKey(*dir)
+		if err != nil {
+			log.Fatalf("error rotating master key: %v", err)
+		}
+		fmt.Printf("Rotated master key: new key %s, %d data keys rewrapped.\n", keyID, rewrapped)
+	}
+
+	// Ключи данных aws:kms выдает внешняя служба ключей или локальный файл ключей
+	if *kmsEndpoint != "" {
+		object.SetKMS(kms.NewHTTPClient(*kmsEndpoint))
+	} else {
+		localKMS, err := kms.LoadLocal(filepath.Join(*dir, kms.LocalKeysFileName))
+		if err != nil {
+			log.Fatalf("error loading KMS keys: %v", err)
+		}
+		object.SetKMS(localKMS)
+	}
+
+	handler := server.SetupRoutes(*dir, *domain, *websiteDomain)
+	website := server.SetupWebsite(*dir, *websiteDomain)
+	if *authEnabled {
+		store, err := auth.LoadStore(*dir)
+		if err != nil {
+			log.Fatalf("error loading credentials: %v", err)
+		}
+		// При первом запуске создаем ключ, чтобы сервер не оказался недоступен
+		if store.Len() == 0 {
+			accessKey, secretKey, err := store.Generate()
+			if err != nil {
+				log.Fatalf("error generating credentials: %v", err)
+			}
+			fmt.Printf("Generated access key: %s\nGenerated secret key: %s\n", accessKey, secretKey)
+		}
+		// Ведра, созданные без аутентификации, передаются первому ключу доступа
+		owner := store.AccessKeys()[0]
+		assigned, err := bucket.AssignOwner(*dir, owner)
+		if err != nil {
+			log.Fatalf("error assigning bucket owners: %v", err)
+		}
+		if assigned > 0 {
+			fmt.Printf("Assigned %d buckets without an owner to access key %s.\n", assigned, owner)
+		}
+		handler = auth.Middleware(handler, store)
+		website = auth.Middleware(website, store)
+	}
+
+	if *lifecycleInterval > 0 {
+		object.StartLifecycleWorker(*dir, *lifecycleInterval)
+	}
+
 	fmt.Printf("Starting server on port %v\n", portNum)
 	fmt.Printf("Using directory: %s\n", *dir)
 
-	if err := http.ListenAndServe(":"+(*port), server.SetupRoutes(*dir)); err != nil {
+	// Сайты ведер отдаются на отдельном порту, чтобы их адреса не пересекались с API
+	if *websitePort != "" {
+		fmt.Printf("Starting website endpoint on port %v\n", *websitePort)
+		go func() {
+			if err := http.ListenAndServe(":"+(*websitePort), website); err != nil {
+				log.Fatalf("Failed to start website endpoint: %v\n", err)
+			}
+		}()
+	}
+
+	if err := http.ListenAndServe(":"+(*port), handler); err != nil {
 		log.Fatalf("Failed to start server: %v\n", err)
 	}
 }
@@ -47,13 +153,35 @@ func showHelp() {
 	
 	
 **Usage:**
-    triple-s [-port <N>] [-dir <S>]  
+    triple-s [-port <N>] [-dir <S>] [-auth] [-domain <H>] [-website-domain <H>] [-website-port <N>] [-master-key <F>] [-rotate-master-key] [-kms <URL>] [-lifecycle-interval <D>]
+    triple-s presign -bucket <B> -key <K> [-method GET|PUT] [-expires <D>] [-endpoint <URL>] [-dir <S>]
+    triple-s kms [-port <N>] [-keys <F>]
     triple-s --help
 
 **Options:**
   --help     Show this screen.
   --port N   Port number
-  --dir S    Path to the directory`
+  --dir S    Path to the directory
+  --auth     Require AWS Signature Version 4 authentication.
+             Keys are read from <dir>/credentials.csv (access key,secret key).
+  --domain H Base domain for virtual-hosted-style addressing:
+             requests to <bucket>.H/<key> address the bucket by host.
+  --website-domain H
+             Domain for static website hosting: requests to <bucket>.H/<path>
+             on either port are served as the bucket's website.
+  --website-port N
+             Port number of a separate website endpoint; it also accepts
+             path-style requests to /<bucket>/<path>.
+  --master-key F
+             Master key file for server-side encryption (default <dir>/master.key).
+             Created on first start; keep it with backups of the data directory.
+  --rotate-master-key
+             Generate a new master key and rewrap all data keys with it
+             before the server starts. The old key is then removed.
+  --kms URL  External key management service for aws:kms encryption.
+             Without it, KMS keys are read from <dir>/kms.keys.
+  --lifecycle-interval D
+             How often bucket lifecycle rules are applied (default 1h, 0 disables).`
 
 	fmt.Println(helpMessage)
 }
@@ -0,0 +1,254 @@
+package acl
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"triple-s/pkg/s3error"
+)
+
+// Разрешения ACL
+const (
+	PermissionRead        = "READ"
+	PermissionWrite       = "WRITE"
+	PermissionReadACP     = "READ_ACP"
+	PermissionWriteACP    = "WRITE_ACP"
+	PermissionFullControl = "FULL_CONTROL"
+)
+
+// Группы получателей разрешений
+const (
+	AllUsers           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	AuthenticatedUsers = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+// Стандартные (canned) ACL, задаваемые заголовком x-amz-acl
+const (
+	CannedPrivate           = "private"
+	CannedPublicRead        = "public-read"
+	CannedPublicReadWrite   = "public-read-write"
+	CannedAuthenticatedRead = "authenticated-read"
+)
+
+// bucketACLFileName — файл ACL в каталоге ведра
+const bucketACLFileName = "acl.xml"
+
+// ACL — владелец ресурса и выданные разрешения
+type ACL struct {
+	// Owner — ключ доступа владельца; пустой у ведер, созданных без аутентификации
+	Owner  string
+	Grants []Grant
+}
+
+// Grant — разрешение для ключа доступа или группы AllUsers/AuthenticatedUsers
+type Grant struct {
+	Grantee    string
+	Permission string
+}
+
+// Canned строит ACL по имени стандартного ACL; пустое имя означает private
+func Canned(name, owner string) (ACL, error) {
+	a := ACL{Owner: owner}
+	if owner != "" {
+		a.Grants = append(a.Grants, Grant{owner, PermissionFullControl})
+	}
+	switch name {
+	case "", CannedPrivate:
+	case CannedPublicRead:
+		a.Grants = append(a.Grants, Grant{AllUsers, PermissionRead})
+	case CannedPublicReadWrite:
+		a.Grants = append(a.Grants, Grant{AllUsers, PermissionRead}, Grant{AllUsers, PermissionWrite})
+	case CannedAuthenticatedRead:
+		a.Grants = append(a.Grants, Grant{AuthenticatedUsers, PermissionRead})
+	default:
+		return ACL{}, s3error.ErrInvalidArgument.WithMessage("unsupported canned ACL: " + name)
+	}
+	return a, nil
+}
+
+// IsOwner сообщает, владеет ли субъект ресурсом. Ресурсом без владельца не владеет никто.
+func (a ACL) IsOwner(principal string) bool {
+	return principal != "" && a.Owner == principal
+}
+
+// Allows сообщает, есть ли у субъекта разрешение permission.
+// principal — ключ доступа или пустая строка для анонимного запроса.
+func (a ACL) Allows(principal, permission string) bool {
+	if a.IsOwner(principal) {
+		return true
+	}
+	for _, grant := range a.Grants {
+		if grant.Permission != permission && grant.Permission != PermissionFullControl {
+			continue
+		}
+		switch grant.Grantee {
+		case AllUsers:
+			return true
+		case AuthenticatedUsers, principal:
+			if principal != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Encode сериализует ACL в строку вида owner=KEY&READ=grantee&... для хранения в CSV
+func (a ACL) Encode() string {
+	if a.Owner == "" && len(a.Grants) == 0 {
+		return ""
+	}
+	values := url.Values{"owner": {a.Owner}}
+	for _, grant := range a.Grants {
+		values.Add(grant.Permission, grant.Grantee)
+	}
+	return values.Encode()
+}
+
+// Decode разбирает строку, созданную Encode; пустая строка дает ACL без владельца
+func Decode(encoded string) (ACL, error) {
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		return ACL{}, err
+	}
+	a := ACL{Owner: values.Get("owner")}
+	permissions := make([]string, 0, len(values))
+	for permission := range values {
+		if permission != "owner" {
+			permissions = append(permissions, permission)
+		}
+	}
+	sort.Strings(permissions)
+	for _, permission := range permissions {
+		for _, grantee := range values[permission] {
+			a.Grants = append(a.Grants, Grant{grantee, permission})
+		}
+	}
+	return a, nil
+}
+
+// AccessControlPolicy — тело PutBucketAcl/PutObjectAcl и ответ GetBucketAcl/GetObjectAcl
+type AccessControlPolicy struct {
+	XMLName xml.Name
+	Owner   *Owner        `xml:"Owner"`
+	Grants  []PolicyGrant `xml:"AccessControlList>Grant"`
+}
+
+// Owner — владелец ресурса в AccessControlPolicy
+type Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName,omitempty"`
+}
+
+// PolicyGrant — разрешение в AccessControlPolicy
+type PolicyGrant struct {
+	Grantee    Grantee `xml:"Grantee"`
+	Permission string  `xml:"Permission"`
+}
+
+// Grantee — получатель разрешения: пользователь (ID) или группа (URI).
+// Атрибуты xsi записываются с префиксом как есть: клиенты S3 ожидают именно его.
+type Grantee struct {
+	XMLNSXSI     string `xml:"xmlns:xsi,attr,omitempty"`
+	Type         string `xml:"xsi:type,attr,omitempty"`
+	ID           string `xml:"ID,omitempty"`
+	DisplayName  string `xml:"DisplayName,omitempty"`
+	URI          string `xml:"URI,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+}
+
+// Policy строит документ AccessControlPolicy для ответа
+func (a ACL) Policy() AccessControlPolicy {
+	policy := AccessControlPolicy{
+		XMLName: s3error.XMLName("AccessControlPolicy"),
+		Owner:   &Owner{ID: a.Owner, DisplayName: a.Owner},
+	}
+	for _, grant := range a.Grants {
+		grantee := Grantee{XMLNSXSI: "http://www.w3.org/2001/XMLSchema-instance"}
+		if grant.Grantee == AllUsers || grant.Grantee == AuthenticatedUsers {
+			grantee.Type, grantee.URI = "Group", grant.Grantee
+		} else {
+			grantee.Type, grantee.ID, grantee.DisplayName = "CanonicalUser", grant.Grantee, grant.Grantee
+		}
+		policy.Grants = append(policy.Grants, PolicyGrant{Grantee: grantee, Permission: grant.Permission})
+	}
+	return policy
+}
+
+// FromPolicy проверяет документ из запроса и строит по нему ACL владельца owner.
+// Поддерживаются получатели по ID и группы AllUsers/AuthenticatedUsers.
+func FromPolicy(policy AccessControlPolicy, owner string) (ACL, error) {
+	a := ACL{Owner: owner}
+	for _, grant := range policy.Grants {
+		switch grant.Permission {
+		case PermissionRead, PermissionWrite, PermissionReadACP, PermissionWriteACP, PermissionFullControl:
+		default:
+			return ACL{}, s3error.ErrMalformedACL.WithMessage("invalid permission: " + grant.Permission)
+		}
+		grantee := grant.Grantee
+		switch {
+		case grantee.URI != "":
+			if grantee.URI != AllUsers && grantee.URI != AuthenticatedUsers {
+				return ACL{}, s3error.ErrInvalidArgument.WithMessage("invalid group uri: " + grantee.URI)
+			}
+			a.Grants = append(a.Grants, Grant{grantee.URI, grant.Permission})
+		case grantee.ID != "":
+			a.Grants = append(a.Grants, Grant{grantee.ID, grant.Permission})
+		case grantee.EmailAddress != "":
+			return ACL{}, s3error.ErrNotImplemented.WithMessage("grants by email address are not supported")
+		default:
+			return ACL{}, s3error.ErrMalformedACL.WithMessage("a grant must name a grantee by ID or URI")
+		}
+	}
+	return a, nil
+}
+
+// LoadBucket читает ACL ведра; отсутствующий файл дает ACL без владельца
+func LoadBucket(dataDir, bucketName string) (ACL, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, bucketName, bucketACLFileName))
+	if os.IsNotExist(err) {
+		return ACL{}, nil
+	} else if err != nil {
+		return ACL{}, fmt.Errorf("unable to read bucket ACL: %v", err)
+	}
+	var policy AccessControlPolicy
+	if err := xml.Unmarshal(data, &policy); err != nil {
+		return ACL{}, fmt.Errorf("malformed bucket ACL: %v", err)
+	}
+	owner := ""
+	if policy.Owner != nil {
+		owner = policy.Owner.ID
+	}
+	return FromPolicy(policy, owner)
+}
+
+// SaveBucket атомарно записывает ACL ведра
+func SaveBucket(dataDir, bucketName string, a ACL) error {
+	policy := a.Policy()
+	policy.XMLName = xml.Name{Local: "AccessControlPolicy"}
+	data, err := xml.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("unable to encode bucket ACL: %v", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Join(dataDir, bucketName), "."+bucketACLFileName+"-*")
+	if err != nil {
+		return fmt.Errorf("unable to save bucket ACL: %v", err)
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(dataDir, bucketName, bucketACLFileName))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("unable to save bucket ACL: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"triple-s/pkg/s3error"
+)
+
+const (
+	// StreamingPayloadTrailer — подписанные фрагменты aws-chunked с подписанными завершающими заголовками
+	StreamingPayloadTrailer = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	// StreamingUnsignedTrailer — неподписанные фрагменты aws-chunked с завершающими заголовками
+	StreamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	chunkAlgorithm   = "AWS4-HMAC-SHA256-PAYLOAD"
+	trailerAlgorithm = "AWS4-HMAC-SHA256-TRAILER"
+	// maxChunkLineSize ограничивает длину строки заголовка фрагмента и завершающего заголовка
+	maxChunkLineSize = 4 << 10
+	// trailerSignatureName — завершающий заголовок с подписью остальных завершающих заголовков
+	trailerSignatureName = "x-amz-trailer-signature"
+)
+
+// emptySHA256 — SHA-256 пустой строки в шестнадцатеричном виде
+var emptySHA256 = hex.EncodeToString(sha256.New().Sum(nil))
+
+// checksumAlgorithms — поддерживаемые контрольные суммы x-amz-checksum-*
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"x-amz-checksum-crc32":     func() hash.Hash { return crc32.NewIEEE() },
+	"x-amz-checksum-crc32c":    func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+	"x-amz-checksum-crc64nvme": func() hash.Hash { return crc64.New(crc64.MakeTable(0x9a6c9329ac4bc9b5)) },
+	"x-amz-checksum-sha1":      sha1.New,
+	"x-amz-checksum-sha256":    sha256.New,
+}
+
+// chunkSigner хранит состояние цепочки подписей фрагментов: подпись каждого фрагмента
+// вычисляется от подписи предыдущего, а первого — от подписи заголовка Authorization
+type chunkSigner struct {
+	key      []byte
+	amzDate  string
+	scope    string
+	previous string
+}
+
+// verify проверяет подпись очередного фрагмента по SHA-256 его данных
+func (s *chunkSigner) verify(signature string, dataHash []byte) error {
+	expected := sign(s.key, strings.Join([]string{
+		chunkAlgorithm, s.amzDate, s.scope, s.previous, emptySHA256, hex.EncodeToString(dataHash),
+	}, "\n"))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return s3error.ErrSignatureDoesNotMatch
+	}
+	s.previous = signature
+	return nil
+}
+
+// verifyTrailer проверяет подпись завершающих заголовков в канонической форме "name:value\n"
+func (s *chunkSigner) verifyTrailer(signature, trailer string) error {
+	trailerHash := sha256.Sum256([]byte(trailer))
+	expected := sign(s.key, strings.Join([]string{
+		trailerAlgorithm, s.amzDate, s.scope, s.previous, hex.EncodeToString(trailerHash[:]),
+	}, "\n"))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return s3error.ErrSignatureDoesNotMatch
+	}
+	return nil
+}
+
+// isStreamingPayload сообщает, что тело передается в формате aws-chunked
+func isStreamingPayload(payloadHash string) bool {
+	switch payloadHash {
+	case StreamingPayload, StreamingPayloadTrailer, StreamingUnsignedTrailer:
+		return true
+	}
+	return false
+}
+
+// DecodeStreamingPayload заменяет тело в формате aws-chunked декодирующим потоком, чтобы
+// обработчики получали исходные данные. Если отправитель проверен Middleware, подписи
+// фрагментов и завершающих заголовков проверяются по мере чтения; контрольная сумма из
+// x-amz-trailer сверяется в конце тела. aws-chunked удаляется из Content-Encoding, а
+// ContentLength принимает значение x-amz-decoded-content-length.
+func DecodeStreamingPayload(r *http.Request) error {
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if !isStreamingPayload(payloadHash) {
+		if strings.HasPrefix(payloadHash, "STREAMING-") {
+			return s3error.ErrNotImplemented.WithMessage("unsupported streaming payload: " + payloadHash)
+		}
+		return nil
+	}
+
+	decodedLength, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+	if err != nil || decodedLength < 0 {
+		return s3error.ErrMissingContentLength.WithMessage("you must provide a valid x-amz-decoded-content-length header")
+	}
+
+	reader := &chunkedReader{
+		body:      r.Body,
+		buf:       bufio.NewReaderSize(r.Body, maxChunkLineSize),
+		expected:  decodedLength,
+		signed:    payloadHash != StreamingUnsignedTrailer,
+		trailered: payloadHash != StreamingPayload,
+	}
+	if identity, ok := IdentityFromContext(r.Context()); ok && identity.streaming != nil {
+		signer := *identity.streaming
+		reader.signer = &signer
+	}
+
+	// Объявленные завершающие заголовки: поддерживаются только контрольные суммы
+	for _, name := range strings.Split(r.Header.Get("X-Amz-Trailer"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		newHash, ok := checksumAlgorithms[name]
+		if !ok || reader.checksum != nil {
+			return s3error.ErrInvalidArgument.WithMessage("unsupported x-amz-trailer: " + name)
+		}
+		reader.checksumName, reader.checksum = name, newHash()
+	}
+	if reader.checksum != nil && !reader.trailered {
+		return s3error.ErrInvalidRequest.WithMessage("x-amz-trailer requires a STREAMING-*-TRAILER payload")
+	}
+
+	r.Body = reader
+	r.ContentLength = decodedLength
+	r.Header.Set("Content-Length", strconv.FormatInt(decodedLength, 10))
+	r.Header.Del("X-Amz-Decoded-Content-Length")
+	stripAWSChunked(r.Header)
+	return nil
+}
+
+// stripAWSChunked удаляет aws-chunked из Content-Encoding: это кодирование передачи,
+// а не содержимого, и оно не должно сохраниться вместе с объектом
+func stripAWSChunked(h http.Header) {
+	var encodings []string
+	for _, value := range h.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.TrimSpace(encoding)
+			if encoding != "" && !strings.EqualFold(encoding, "aws-chunked") {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	h.Del("Content-Encoding")
+	if len(encodings) > 0 {
+		h.Set("Content-Encoding", strings.Join(encodings, ","))
+	}
+}
+
+// chunkedReader декодирует поток фрагментов вида
+// "<hex-размер>[;chunk-signature=<подпись>]\r\n<данные>\r\n", завершаемый фрагментом
+// нулевого размера и необязательными завершающими заголовками
+type chunkedReader struct {
+	body io.Closer
+	buf  *bufio.Reader
+	// signer проверяет подписи; nil, если отправитель не проверялся (аутентификация отключена)
+	signer    *chunkSigner
+	signed    bool
+	trailered bool
+
+	checksumName string
+	checksum     hash.Hash
+
+	// remaining — сколько байт данных осталось в текущем фрагменте
+	remaining int64
+	inChunk   bool
+	signature string
+	chunkHash hash.Hash
+
+	decoded  int64
+	expected int64
+	err      error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	for c.remaining == 0 {
+		if c.inChunk {
+			if c.err = c.finishChunk(); c.err != nil {
+				return 0, c.err
+			}
+		}
+		size, err := c.nextChunk()
+		if err != nil {
+			c.err = err
+			return 0, err
+		}
+		if size == 0 {
+			c.err = c.finish()
+			return 0, c.err
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.buf.Read(p)
+	c.remaining -= int64(n)
+	c.decoded += int64(n)
+	c.chunkHash.Write(p[:n])
+	if c.checksum != nil {
+		c.checksum.Write(p[:n])
+	}
+	if c.decoded > c.expected {
+		c.err = s3error.ErrIncompleteBody.WithMessage("the decoded body is longer than x-amz-decoded-content-length")
+		return n, c.err
+	}
+	if err == io.EOF {
+		err = s3error.ErrIncompleteBody
+	}
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+func (c *chunkedReader) Close() error {
+	return c.body.Close()
+}
+
+// nextChunk читает заголовок фрагмента и возвращает размер его данных
+func (c *chunkedReader) nextChunk() (int64, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return 0, err
+	}
+	sizeField, extension, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(sizeField, 16, 64)
+	if err != nil || size < 0 {
+		return 0, s3error.ErrIncompleteBody.WithMessage("malformed chunk header")
+	}
+	c.signature = ""
+	if name, value, found := strings.Cut(extension, "="); found && name == "chunk-signature" {
+		c.signature = value
+	}
+	if c.signed && c.signer != nil && c.signature == "" {
+		return 0, s3error.ErrSignatureDoesNotMatch.WithMessage("a chunk signature is missing")
+	}
+
+	c.remaining, c.inChunk = size, size > 0
+	c.chunkHash = sha256.New()
+	if size == 0 && c.signed && c.signer != nil {
+		if err := c.signer.verify(c.signature, c.chunkHash.Sum(nil)); err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// finishChunk проверяет окончание данных фрагмента и его подпись
+func (c *chunkedReader) finishChunk() error {
+	c.inChunk = false
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return s3error.ErrIncompleteBody.WithMessage("chunk data is longer than its declared size")
+	}
+	if c.signed && c.signer != nil {
+		return c.signer.verify(c.signature, c.chunkHash.Sum(nil))
+	}
+	return nil
+}
+
+// finish разбирает завершающие заголовки после последнего фрагмента и сверяет
+// длину тела и контрольную сумму; при успехе возвращает io.EOF
+func (c *chunkedReader) finish() error {
+	var trailer strings.Builder
+	var trailerSignature, checksumValue string
+	for {
+		line, err := c.readLine()
+		if err == io.EOF || (err == nil && line == "") {
+			break
+		} else if err != nil {
+			return err
+		}
+		name, value, found := strings.Cut(line, ":")
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		switch {
+		case !found || !c.trailered:
+			return s3error.ErrIncompleteBody.WithMessage("malformed trailing header")
+		case name == trailerSignatureName:
+			trailerSignature = value
+		case name == c.checksumName:
+			checksumValue = value
+			trailer.WriteString(name + ":" + value + "\n")
+		default:
+			return s3error.ErrInvalidRequest.WithMessage("the trailing header " + name + " was not declared in x-amz-trailer")
+		}
+	}
+
+	if c.decoded != c.expected {
+		return s3error.ErrIncompleteBody
+	}
+	if c.trailered && c.signed && c.signer != nil && c.checksum != nil {
+		if trailerSignature == "" {
+			return s3error.ErrSignatureDoesNotMatch.WithMessage("the trailer signature is missing")
+		}
+		if err := c.signer.verifyTrailer(trailerSignature, trailer.String()); err != nil {
+			return err
+		}
+	}
+	if c.checksum != nil {
+		if checksumValue == "" {
+			return s3error.ErrInvalidRequest.WithMessage("the trailing header " + c.checksumName + " is missing")
+		}
+		expected, err := base64.StdEncoding.DecodeString(checksumValue)
+		if err != nil || !bytes.Equal(expected, c.checksum.Sum(nil)) {
+			return s3error.ErrBadDigest.WithMessage("The " + c.checksumName + " you specified did not match the calculated checksum.")
+		}
+	}
+	return io.EOF
+}
+
+// readLine читает строку, оканчивающуюся на "\r\n", и возвращает ее без окончания
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.buf.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", s3error.ErrIncompleteBody.WithMessage("chunk header line is too long")
+	} else if err == io.EOF && len(line) == 0 {
+		return "", io.EOF
+	} else if err != nil && err != io.EOF {
+		return "", err
+	}
+	text, found := strings.CutSuffix(string(line), "\r\n")
+	if !found {
+		return "", s3error.ErrIncompleteBody
+	}
+	return text, nil
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CredentialsFileName — файл с парами ключей в каталоге данных
+const CredentialsFileName = "credentials.csv"
+
+// Store хранит пары ключ доступа / секретный ключ из credentials.csv
+type Store struct {
+	path string
+	mu   sync.RWMutex
+	keys map[string]string
+}
+
+// LoadStore читает хранилище ключей из каталога данных.
+// Отсутствующий файл означает пустое хранилище.
+func LoadStore(dataDir string) (*Store, error) {
+	store := &Store{
+		path: filepath.Join(dataDir, CredentialsFileName),
+		keys: make(map[string]string),
+	}
+
+	file, err := os.Open(store.path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error opening credentials file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading credentials file: %v", err)
+	}
+	for _, record := range records {
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			return nil, fmt.Errorf("malformed credentials record: %q", record[0])
+		}
+		store.keys[record[0]] = record[1]
+	}
+	return store, nil
+}
+
+// Lookup возвращает секретный ключ для ключа доступа
+func (s *Store) Lookup(accessKey string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	secret, ok := s.keys[accessKey]
+	return secret, ok
+}
+
+// Len возвращает число ключей в хранилище
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// AccessKeys возвращает отсортированный список ключей доступа
+func (s *Store) AccessKeys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Generate создает новую пару ключей и дописывает ее в credentials.csv
+func (s *Store) Generate() (string, string, error) {
+	accessKey, err := randomString(15)
+	if err != nil {
+		return "", "", err
+	}
+	accessKey = "TS" + strings.ToUpper(strings.NewReplacer("-", "A", "_", "Z").Replace(accessKey))
+	secretKey, err := randomString(30)
+	if err != nil {
+		return "", "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return "", "", fmt.Errorf("error opening credentials file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{accessKey, secretKey}); err != nil {
+		return "", "", fmt.Errorf("error writing credentials file: %v", err)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", "", fmt.Errorf("error writing credentials file: %v", err)
+	}
+
+	s.keys[accessKey] = secretKey
+	return accessKey, secretKey, nil
+}
+
+// randomString возвращает n случайных байт в кодировке base64url
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating key: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"time"
+
+	"triple-s/pkg/s3error"
+)
+
+// Identity описывает отправителя запроса; у анонимного запроса AccessKey пустой
+type Identity struct {
+	AccessKey string
+	// streaming проверяет подписи фрагментов тела aws-chunked
+	streaming *chunkSigner
+}
+
+// Anonymous сообщает, что запрос отправлен без подписи
+func (i *Identity) Anonymous() bool {
+	return i.AccessKey == ""
+}
+
+type identityKey struct{}
+
+// IdentityFromContext возвращает отправителя запроса; ok == false, если аутентификация отключена
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
+
+// Middleware проверяет подпись SigV4 перед передачей запроса обработчику.
+// Запрос без подписи передается дальше как анонимный: доступ к нему решают ACL и политика ведра.
+func Middleware(next http.Handler, store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Предварительные запросы CORS браузер отправляет без подписи
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var identity *Identity
+		var err error
+		switch {
+		case r.Header.Get("Authorization") != "":
+			identity, err = verifyHeaderAuth(r, store, time.Now().UTC())
+		case isPresigned(r):
+			identity, err = verifyPresigned(r, store, time.Now().UTC())
+		default:
+			identity = &Identity{}
+		}
+		if err != nil {
+			s3error.WriteError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	})
+}
+
+// verifyHeaderAuth проверяет подпись из заголовка Authorization
+func verifyHeaderAuth(r *http.Request, store *Store, now time.Time) (*Identity, error) {
+	auth, err := parseAuthorization(r.Header.Get("Authorization"))
+	if err != nil {
+		return nil, err
+	}
+	if !containsString(auth.SignedHeaders, "host") {
+		return nil, s3error.ErrAuthorizationMalformed.WithMessage("the host header must be signed")
+	}
+
+	secretKey, ok := store.Lookup(auth.AccessKey)
+	if !ok {
+		return nil, s3error.ErrInvalidAccessKeyID
+	}
+
+	// 1. Проверка времени запроса
+	signedAt, amzDate, err := requestTime(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClockSkew(signedAt, now); err != nil {
+		return nil, err
+	}
+	if signedAt.Format(scopeDate) != auth.Date {
+		return nil, s3error.ErrAuthorizationMalformed.WithMessage("the credential date does not match the request date")
+	}
+
+	// 2. Хеш тела запроса обязателен для подписи заголовком
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return nil, s3error.ErrMissingSecurityHeader.WithMessage("missing required header for this request: x-amz-content-sha256")
+	}
+
+	// 3. Сравнение подписей
+	canonical := canonicalRequest(r, r.URL.Query(), auth.SignedHeaders, payloadHash)
+	key := signingKey(secretKey, auth.Date, auth.Region)
+	expected := sign(key, stringToSign(amzDate, auth.scope(), canonical))
+	if !hmac.Equal([]byte(expected), []byte(auth.Signature)) {
+		return nil, s3error.ErrSignatureDoesNotMatch
+	}
+
+	// 4. Проверка хеша тела по мере чтения
+	switch payloadHash {
+	case UnsignedPayload, StreamingPayload, StreamingPayloadTrailer, StreamingUnsignedTrailer:
+	default:
+		expectedHash, err := hex.DecodeString(payloadHash)
+		if err != nil || len(expectedHash) != sha256.Size {
+			return nil, s3error.ErrInvalidArgument.WithMessage("x-amz-content-sha256 must be UNSIGNED-PAYLOAD, a STREAMING-* value, or a valid sha256 value")
+		}
+		r.Body = &payloadVerifier{body: r.Body, hash: sha256.New(), expected: expectedHash}
+	}
+
+	identity := &Identity{AccessKey: auth.AccessKey}
+	if payloadHash == StreamingPayload || payloadHash == StreamingPayloadTrailer {
+		// Подпись заголовка служит затравкой для цепочки подписей фрагментов
+		identity.streaming = &chunkSigner{key: key, amzDate: amzDate, scope: auth.scope(), previous: auth.Signature}
+	}
+	return identity, nil
+}
+
+// payloadVerifier сверяет SHA-256 тела запроса с x-amz-content-sha256 по достижении конца тела
+type payloadVerifier struct {
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected []byte
+}
+
+func (v *payloadVerifier) Read(p []byte) (int, error) {
+	n, err := v.body.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF && !hmac.Equal(v.hash.Sum(nil), v.expected) {
+		return n, s3error.ErrContentSHA256Mismatch
+	}
+	return n, err
+}
+
+func (v *payloadVerifier) Close() error {
+	return v.body.Close()
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
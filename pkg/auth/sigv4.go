@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"triple-s/pkg/s3error"
+)
+
+const (
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat = "20060102T150405Z"
+	scopeDate     = "20060102"
+	scopeTerminal = "aws4_request"
+	serviceName   = "s3"
+	maxClockSkew  = 15 * time.Minute
+
+	// UnsignedPayload — значение x-amz-content-sha256 для неподписанного тела
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// StreamingPayload — значение x-amz-content-sha256 для тела aws-chunked с подписанными фрагментами
+	StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+)
+
+// authorization — разобранный заголовок Authorization
+type authorization struct {
+	AccessKey     string
+	Date          string
+	Region        string
+	SignedHeaders []string
+	Signature     string
+}
+
+// parseAuthorization разбирает заголовок вида
+// "AWS4-HMAC-SHA256 Credential=AKID/20130524/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=..."
+func parseAuthorization(header string) (authorization, error) {
+	rest, found := strings.CutPrefix(header, signAlgorithm+" ")
+	if !found {
+		return authorization{}, s3error.ErrAuthorizationMalformed.WithMessage("unsupported authorization algorithm")
+	}
+
+	var auth authorization
+	var credential string
+	for _, field := range strings.Split(rest, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return authorization{}, s3error.ErrAuthorizationMalformed
+		}
+		switch name {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			auth.SignedHeaders = strings.Split(value, ";")
+		case "Signature":
+			auth.Signature = value
+		}
+	}
+	if credential == "" || len(auth.SignedHeaders) == 0 || auth.Signature == "" {
+		return authorization{}, s3error.ErrAuthorizationMalformed
+	}
+
+	if err := auth.parseCredential(credential); err != nil {
+		return authorization{}, err
+	}
+	return auth, nil
+}
+
+// parseCredential разбирает область действия ключа "AKID/date/region/s3/aws4_request"
+func (a *authorization) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != serviceName || parts[4] != scopeTerminal {
+		return s3error.ErrAuthorizationMalformed.WithMessage("the credential scope is invalid")
+	}
+	a.AccessKey, a.Date, a.Region = parts[0], parts[1], parts[2]
+	return nil
+}
+
+// scope возвращает область действия подписи
+func (a authorization) scope() string {
+	return strings.Join([]string{a.Date, a.Region, serviceName, scopeTerminal}, "/")
+}
+
+// requestTime определяет время запроса по X-Amz-Date или Date
+func requestTime(r *http.Request) (time.Time, string, error) {
+	if amzDate := r.Header.Get("X-Amz-Date"); amzDate != "" {
+		t, err := time.Parse(amzDateFormat, amzDate)
+		if err != nil {
+			return time.Time{}, "", s3error.ErrAccessDenied.WithMessage("X-Amz-Date must be in the ISO8601 Long Format")
+		}
+		return t, amzDate, nil
+	}
+	if date := r.Header.Get("Date"); date != "" {
+		t, err := http.ParseTime(date)
+		if err != nil {
+			return time.Time{}, "", s3error.ErrAccessDenied.WithMessage("the Date header is malformed")
+		}
+		return t.UTC(), t.UTC().Format(amzDateFormat), nil
+	}
+	return time.Time{}, "", s3error.ErrAccessDenied.WithMessage("AWS authentication requires a valid Date or x-amz-date header")
+}
+
+// checkClockSkew проверяет, что время запроса не слишком расходится с часами сервера
+func checkClockSkew(t, now time.Time) error {
+	if t.Before(now.Add(-maxClockSkew)) || t.After(now.Add(maxClockSkew)) {
+		return s3error.ErrRequestTimeTooSkewed
+	}
+	return nil
+}
+
+// canonicalRequest строит каноническую форму запроса SigV4
+func canonicalRequest(r *http.Request, query url.Values, signedHeaders []string, payloadHash string) string {
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(query),
+		canonicalHeaders(r, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// canonicalQuery сортирует и кодирует параметры запроса
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders формирует блок подписанных заголовков; каждый заканчивается переводом строки
+func canonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		switch name {
+		case "host":
+			values = []string{r.Host}
+		case "content-length":
+			values = append([]string(nil), r.Header.Values("Content-Length")...)
+			if len(values) == 0 && r.ContentLength >= 0 {
+				values = []string{strconv.FormatInt(r.ContentLength, 10)}
+			}
+		case "transfer-encoding":
+			values = append([]string(nil), r.TransferEncoding...)
+		default:
+			values = append([]string(nil), r.Header.Values(name)...)
+		}
+		for i, value := range values {
+			values[i] = strings.Join(strings.Fields(value), " ")
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(values, ","))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// uriEncode кодирует строку по правилам SigV4: не кодируются только A-Z, a-z, 0-9, '-', '_', '.', '~'
+// и, если encodeSlash == false, символ '/'
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+// stringToSign строит строку для подписи
+func stringToSign(amzDate, scope, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	return strings.Join([]string{signAlgorithm, amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+}
+
+// signingKey выводит ключ подписи из секретного ключа и области действия
+func signingKey(secretKey, date, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, serviceName)
+	return hmacSHA256(key, scopeTerminal)
+}
+
+// sign вычисляет подпись строки в шестнадцатеричном виде
+func sign(key []byte, data string) string {
+	return hex.EncodeToString(hmacSHA256(key, data))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -9,7 +9,15 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
+
+	"triple-s/pkg/acl"
+	"triple-s/pkg/auth"
+	"triple-s/pkg/kms"
+	"triple-s/pkg/object"
+	"triple-s/pkg/s3error"
+	"triple-s/pkg/sse"
 )
 
 // Bucket представляет структуру ведра
@@ -21,6 +29,11 @@ type Bucket struct {
 	Status           string   `xml:"Status"`
 }
 
+// reservedNames — файлы хранилища в корне dataDir, которые ведро не может занять.
+// Служебные каталоги хранилища (_tmp, _multipart, _lifecycle) начинаются с '_': шаблон имени
+// в validateBucketName не допускает этот символ, поэтому такие каталоги никогда не совпадут с ведром.
+var reservedNames = []string{"buckets.csv", sse.MasterKeyFileName, kms.LocalKeysFileName, auth.CredentialsFileName}
+
 // validateBucketName проверяет имя ведра на соответствие правилам
 func validateBucketName(bucketName string) (bool, string) {
 	const namePattern = `^[a-z0-9.-]{3,63}$`
@@ -40,10 +53,24 @@ func validateBucketName(bucketName string) (bool, string) {
 	if net.ParseIP(bucketName) != nil {
 		return false, "bucket name must not be formatted as an IP address"
 	}
+	for _, reserved := range reservedNames {
+		if bucketName == reserved {
+			return false, "bucket name is reserved"
+		}
+	}
 
 	return true, ""
 }
 
+// ValidateName проверяет имя ведра из запроса. Имя становится каталогом в dataDir,
+// поэтому маршрутизатор отклоняет некорректные имена до обработчиков.
+func ValidateName(bucketName string) error {
+	if valid, msg := validateBucketName(bucketName); !valid {
+		return s3error.ErrInvalidBucketName.WithMessage(msg)
+	}
+	return nil
+}
+
 // isBucketNameUnique проверяет уникальность имени ведра по данным в CSV
 func isBucketNameUnique(bucketName, csvFilePath string) (bool, error) {
 	file, err := os.Open(csvFilePath)
@@ -71,12 +98,12 @@ func isBucketNameUnique(bucketName, csvFilePath string) (bool, error) {
 	return true, nil
 }
 
-// createBucket создает ведро, директорию и записывает информацию о ведре в CSV
-func createBucket(bucketName, csvFilePath, dataDir string) (Bucket, error) {
+// createBucket создает ведро, директорию и записывает информацию о ведре в CSV.
+// objectLock включает в новом ведре Object Lock и версионирование.
+func createBucket(bucketName, csvFilePath, dataDir string, bucketACL acl.ACL, objectLock bool) (Bucket, error) {
 	// 1. Проверка имени ведра
-	valid, msg := validateBucketName(bucketName)
-	if !valid {
-		return Bucket{}, fmt.Errorf("400 bad request: %s", msg)
+	if err := ValidateName(bucketName); err != nil {
+		return Bucket{}, err
 	}
 
 	// 2. Проверка уникальности имени ведра
@@ -85,7 +112,7 @@ func createBucket(bucketName, csvFilePath, dataDir string) (Bucket, error) {
 		return Bucket{}, fmt.Errorf("error checking bucket uniqueness: %v", err)
 	}
 	if !unique {
-		return Bucket{}, fmt.Errorf("409 conflict: bucket name already exists")
+		return Bucket{}, s3error.ErrBucketAlreadyExists
 	}
 
 	// 3. Создание подкаталога для ведра
@@ -95,7 +122,21 @@ func createBucket(bucketName, csvFilePath, dataDir string) (Bucket, error) {
 		return Bucket{}, fmt.Errorf("error creating bucket directory: %v", err)
 	}
 
-	// 5. Создаем информацию о ведре
+	// 4. Сохранение ACL ведра
+	if err := acl.SaveBucket(dataDir, bucketName, bucketACL); err != nil {
+		os.Remove(bucketPath)
+		return Bucket{}, err
+	}
+
+	// 5. Включение Object Lock до того, как ведро появится в списке
+	if objectLock {
+		if err := object.EnableObjectLock(dataDir, bucketName); err != nil {
+			os.RemoveAll(bucketPath)
+			return Bucket{}, err
+		}
+	}
+
+	// 6. Создаем информацию о ведре
 	creationTime := time.Now().Format(time.RFC3339)
 	lastModifiedTime := creationTime
 	status := "active"
@@ -107,7 +148,7 @@ func createBucket(bucketName, csvFilePath, dataDir string) (Bucket, error) {
 		Status:           status,
 	}
 
-	// 6. Запись информации о ведре в CSV файл
+	// 7. Запись информации о ведре в CSV файл
 	file, err := os.OpenFile(csvFilePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
 	if err != nil {
 		return Bucket{}, fmt.Errorf("error opening CSV file: %v", err)
@@ -129,7 +170,28 @@ func createBucket(bucketName, csvFilePath, dataDir string) (Bucket, error) {
 // CreateBucketHandler обрабатывает HTTP-запросы на создание ведра
 func CreateBucketHandler(w http.ResponseWriter, r *http.Request, dataDir, bucketName string) {
 	if bucketName == "" {
-		http.Error(w, "400 bad request: missing bucket name in the URL", http.StatusBadRequest)
+		s3error.WriteError(w, r, s3error.ErrInvalidBucketName.WithMessage("missing bucket name in the URL"))
+		return
+	}
+
+	// Владельцем ведра становится отправитель запроса
+	owner := ""
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+		owner = identity.AccessKey
+	}
+	bucketACL, err := acl.Canned(r.Header.Get("x-amz-acl"), owner)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// Object Lock можно включить только при создании ведра или для версионируемого ведра
+	objectLock := false
+	switch value := r.Header.Get("x-amz-bucket-object-lock-enabled"); {
+	case strings.EqualFold(value, "true"):
+		objectLock = true
+	case value != "" && !strings.EqualFold(value, "false"):
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("x-amz-bucket-object-lock-enabled must be true or false"))
 		return
 	}
 
@@ -137,24 +199,12 @@ func CreateBucketHandler(w http.ResponseWriter, r *http.Request, dataDir, bucket
 	csvFilePath := filepath.Join(dataDir, "buckets.csv")
 
 	// Вызов функции createBucket для создания ведра
-	bucket, err := createBucket(bucketName, csvFilePath, dataDir)
+	bucket, err := createBucket(bucketName, csvFilePath, dataDir, bucketACL, objectLock)
 	if err != nil {
-		if err.Error()[:3] == "400" {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		} else if err.Error()[:3] == "409" {
-			http.Error(w, err.Error(), http.StatusConflict)
-		} else {
-			http.Error(w, "500 internal server error: "+err.Error(), http.StatusInternalServerError)
-		}
+		s3error.WriteError(w, r, err)
 		return
 	}
 
-	// Устанавливаем заголовок Content-Type для XML
-	w.Header().Set("Content-Type", "application/xml")
-
 	// Кодируем структуру ведра в XML
-	w.WriteHeader(http.StatusOK)
-	if err := xml.NewEncoder(w).Encode(bucket); err != nil {
-		http.Error(w, "500 internal server error: unable to encode XML", http.StatusInternalServerError)
-	}
+	s3error.WriteXML(w, r, http.StatusOK, bucket)
 }
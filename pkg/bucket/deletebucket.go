@@ -6,29 +6,15 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+
+	"triple-s/pkg/object"
+	"triple-s/pkg/s3error"
 )
 
 func deleteBucket(bucketName string, csvFilePath string, dataDir string) error {
-	// Проверяем, существует ли ведро
-	bucketPath := fmt.Sprintf("%s/%s", dataDir, bucketName)
-	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
-		return fmt.Errorf("bucket not found")
-	}
-
-	// Проверяем, есть ли в ведре объекты
-	files, err := os.ReadDir(bucketPath)
-	if err != nil {
-		return fmt.Errorf("error reading bucket directory: %v", err)
-	}
-	if len(files) > 0 {
-		return fmt.Errorf("bucket is not empty, delete objects before deleting the bucket")
-	}
-
-	// Удаляем директорию ведра
-	err = os.RemoveAll(bucketPath)
-	if err != nil {
-		return fmt.Errorf("error deleting bucket directory: %v", err)
+	// Проверяем, что ведро существует и пусто, и удаляем его директорию
+	if err := object.RemoveBucket(dataDir, bucketName); err != nil {
+		return err
 	}
 
 	// Читаем существующие ведра из CSV
@@ -72,10 +58,8 @@ func deleteBucket(bucketName string, csvFilePath string, dataDir string) error {
 
 // DeleteBucketHandler обрабатывает HTTP-запросы на удаление ведра.
 func DeleteBucketHandler(w http.ResponseWriter, r *http.Request, dataDir, bucketName string) {
-	// bucketName := r.URL.Path[len("/delete/"):] // Измените на len("/buckets/delete/") для более точного извлечения имени
-
 	if bucketName == "" {
-		http.Error(w, "400 Bad Request: Missing bucket name in the URL", http.StatusBadRequest)
+		s3error.WriteError(w, r, s3error.ErrInvalidBucketName.WithMessage("missing bucket name in the URL"))
 		return
 	}
 
@@ -83,13 +67,7 @@ func DeleteBucketHandler(w http.ResponseWriter, r *http.Request, dataDir, bucket
 
 	err := deleteBucket(bucketName, csvFilePath, dataDir)
 	if err != nil {
-		if err.Error() == "bucket not found" {
-			http.Error(w, "404 Not Found: Bucket not found", http.StatusNotFound)
-		} else if strings.Contains(err.Error(), "bucket is not empty") {
-			http.Error(w, "400 Bad Request: "+err.Error(), http.StatusBadRequest)
-		} else {
-			http.Error(w, "500 Internal Server Error: "+err.Error(), http.StatusInternalServerError)
-		}
+		s3error.WriteError(w, r, err)
 		return
 	}
 
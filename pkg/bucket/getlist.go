@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+
+	"triple-s/pkg/s3error"
 )
 
 // Структура для формирования XML-ответа
@@ -18,7 +20,10 @@ type ListAllBucketsResponse struct {
 // Функция для получения всех ведер из CSV файла
 func getAllBucketsFromCSV(csvFilePath string) ([]Bucket, error) {
 	file, err := os.Open(csvFilePath)
-	if err != nil {
+	if os.IsNotExist(err) {
+		// Ни одного ведра еще не создано
+		return nil, nil
+	} else if err != nil {
 		return nil, fmt.Errorf("error opening CSV file: %v", err)
 	}
 	defer file.Close()
@@ -46,7 +51,7 @@ func getAllBucketsFromCSV(csvFilePath string) ([]Bucket, error) {
 // ListAllBucketsHandler обрабатывает HTTP-запросы на получение списка ведер.
 func ListAllBucketsHandler(w http.ResponseWriter, r *http.Request, dataDir string) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		s3error.WriteError(w, r, s3error.ErrMethodNotAllowed)
 		return
 	}
 
@@ -54,20 +59,30 @@ func ListAllBucketsHandler(w http.ResponseWriter, r *http.Request, dataDir strin
 
 	buckets, err := getAllBucketsFromCSV(csvFilePath)
 	if err != nil {
-		http.Error(w, "500 Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+		s3error.WriteError(w, r, err)
 		return
 	}
 
 	// Формируем ответ в формате XML
 	response := ListAllBucketsResponse{Buckets: buckets}
+	s3error.WriteXML(w, r, http.StatusOK, response)
+}
 
-	// Устанавливаем Content-Type для XML
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusOK)
+// HeadBucketHandler проверяет существование ведра без тела ответа
+func HeadBucketHandler(w http.ResponseWriter, r *http.Request, dataDir, bucketName string) {
+	if bucketName == "" {
+		s3error.WriteError(w, r, s3error.ErrInvalidBucketName.WithMessage("missing bucket name in the URL"))
+		return
+	}
 
-	// Кодируем ответ в XML и отправляем его клиенту
-	err = xml.NewEncoder(w).Encode(response)
-	if err != nil {
-		http.Error(w, "500 Internal Server Error: Failed to encode XML response", http.StatusInternalServerError)
+	bucketPath := filepath.Join(dataDir, bucketName)
+	if info, err := os.Stat(bucketPath); os.IsNotExist(err) || (err == nil && !info.IsDir()) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	} else if err != nil {
+		s3error.WriteError(w, r, err)
+		return
 	}
+
+	w.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,35 @@
+package bucket
+
+import (
+	"path/filepath"
+
+	"triple-s/pkg/acl"
+)
+
+// AssignOwner передает ведра без владельца ключу доступа owner и возвращает их число.
+// Владельца нет у ведер, созданных без аутентификации; с -auth ими иначе не мог бы
+// управлять никто. Выданные ранее разрешения сохраняются.
+func AssignOwner(dataDir, owner string) (int, error) {
+	buckets, err := getAllBucketsFromCSV(filepath.Join(dataDir, "buckets.csv"))
+	if err != nil {
+		return 0, err
+	}
+
+	assigned := 0
+	for _, bucket := range buckets {
+		bucketACL, err := acl.LoadBucket(dataDir, bucket.Name)
+		if err != nil {
+			return assigned, err
+		}
+		if bucketACL.Owner != "" {
+			continue
+		}
+		bucketACL.Owner = owner
+		bucketACL.Grants = append([]acl.Grant{{Grantee: owner, Permission: acl.PermissionFullControl}}, bucketACL.Grants...)
+		if err := acl.SaveBucket(dataDir, bucket.Name, bucketACL); err != nil {
+			return assigned, err
+		}
+		assigned++
+	}
+	return assigned, nil
+}
@@ -0,0 +1,92 @@
+package kms
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Handler отдает службу ключей k по протоколу, который понимает HTTPClient.
+// Вместе с Local он служит локальной заменой внешней службы ключей.
+func Handler(k KMS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeFailure(w, http.StatusMethodNotAllowed, "UnsupportedOperationException", "only POST is supported")
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+		if err != nil {
+			writeFailure(w, http.StatusBadRequest, "ValidationException", "unable to read request body")
+			return
+		}
+
+		switch operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix); operation {
+		case "GenerateDataKey":
+			var request generateDataKeyRequest
+			if err := json.Unmarshal(body, &request); err != nil || request.KeyID == "" {
+				writeFailure(w, http.StatusBadRequest, "ValidationException", "KeyId is required")
+				return
+			}
+			if request.KeySpec != "" && request.KeySpec != "AES_256" {
+				writeFailure(w, http.StatusBadRequest, "ValidationException", "only the AES_256 key spec is supported")
+				return
+			}
+			plaintext, ciphertext, err := k.GenerateDataKey(request.KeyID)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, generateDataKeyResponse{KeyID: request.KeyID, Plaintext: plaintext, CiphertextBlob: ciphertext})
+		case "Decrypt":
+			var request decryptRequest
+			if err := json.Unmarshal(body, &request); err != nil || request.KeyID == "" {
+				writeFailure(w, http.StatusBadRequest, "ValidationException", "KeyId and CiphertextBlob are required")
+				return
+			}
+			plaintext, err := k.Decrypt(request.KeyID, request.CiphertextBlob)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, decryptResponse{KeyID: request.KeyID, Plaintext: plaintext})
+		case "ListKeys":
+			keys, err := k.ListKeys()
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			response := listKeysResponse{Keys: []keyListEntry{}}
+			for _, keyID := range keys {
+				response.Keys = append(response.Keys, keyListEntry{KeyID: keyID})
+			}
+			writeJSON(w, response)
+		default:
+			writeFailure(w, http.StatusBadRequest, "UnknownOperationException", "unknown operation "+operation)
+		}
+	})
+}
+
+// writeError отвечает ошибкой службы ключей: неизвестный ключ — NotFoundException,
+// остальные ошибки считаются ошибками расшифровки
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrKeyNotFound) {
+		writeFailure(w, http.StatusBadRequest, "NotFoundException", err.Error())
+		return
+	}
+	log.Printf("KMS error: %v", err)
+	writeFailure(w, http.StatusBadRequest, "InvalidCiphertextException", "unable to process the request")
+}
+
+func writeFailure(w http.ResponseWriter, status int, errorType, message string) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Type: errorType, Message: message})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", contentType)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,139 @@
+package kms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Протокол повторяет JSON API AWS KMS: POST с заголовком X-Amz-Target: TrentService.<Операция>,
+// двоичные поля передаются в base64. Запросы не подписываются.
+const (
+	targetPrefix = "TrentService."
+	contentType  = "application/x-amz-json-1.1"
+	// maxMessageSize ограничивает размер запросов и ответов службы ключей
+	maxMessageSize = 1 << 20
+)
+
+type generateDataKeyRequest struct {
+	KeyID   string `json:"KeyId"`
+	KeySpec string `json:"KeySpec,omitempty"`
+}
+
+type generateDataKeyResponse struct {
+	KeyID          string `json:"KeyId"`
+	Plaintext      []byte `json:"Plaintext"`
+	CiphertextBlob []byte `json:"CiphertextBlob"`
+}
+
+type decryptRequest struct {
+	KeyID          string `json:"KeyId"`
+	CiphertextBlob []byte `json:"CiphertextBlob"`
+}
+
+type decryptResponse struct {
+	KeyID     string `json:"KeyId"`
+	Plaintext []byte `json:"Plaintext"`
+}
+
+type listKeysResponse struct {
+	Keys      []keyListEntry `json:"Keys"`
+	Truncated bool           `json:"Truncated"`
+}
+
+type keyListEntry struct {
+	KeyID string `json:"KeyId"`
+}
+
+type errorResponse struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// HTTPClient обращается к внешней службе ключей по протоколу AWS KMS
+type HTTPClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPClient создает клиента службы ключей с адресом endpoint (например, http://localhost:9200)
+func NewHTTPClient(endpoint string) *HTTPClient {
+	return &HTTPClient{endpoint: endpoint, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// GenerateDataKey запрашивает у службы ключ данных AES_256 под ключом keyID
+func (c *HTTPClient) GenerateDataKey(keyID string) ([]byte, []byte, error) {
+	var response generateDataKeyResponse
+	if err := c.call("GenerateDataKey", generateDataKeyRequest{KeyID: keyID, KeySpec: "AES_256"}, &response); err != nil {
+		return nil, nil, err
+	}
+	if len(response.Plaintext) != DataKeySize || len(response.CiphertextBlob) == 0 {
+		return nil, nil, fmt.Errorf("KMS returned a malformed data key")
+	}
+	return response.Plaintext, response.CiphertextBlob, nil
+}
+
+// Decrypt просит службу расшифровать ключ данных
+func (c *HTTPClient) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	var response decryptResponse
+	if err := c.call("Decrypt", decryptRequest{KeyID: keyID, CiphertextBlob: ciphertext}, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Plaintext) != DataKeySize {
+		return nil, fmt.Errorf("KMS returned a malformed data key")
+	}
+	return response.Plaintext, nil
+}
+
+// ListKeys возвращает идентификаторы ключей службы
+func (c *HTTPClient) ListKeys() ([]string, error) {
+	var response listKeysResponse
+	if err := c.call("ListKeys", struct{}{}, &response); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(response.Keys))
+	for _, key := range response.Keys {
+		keys = append(keys, key.KeyID)
+	}
+	return keys, nil
+}
+
+// call выполняет операцию operation; ошибка NotFoundException превращается в ErrKeyNotFound
+func (c *HTTPClient) call(operation string, request, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("KMS %s: %v", operation, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("KMS %s: %v", operation, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Target", targetPrefix+operation)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("KMS %s: %v", operation, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return fmt.Errorf("KMS %s: %v", operation, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure errorResponse
+		json.Unmarshal(data, &failure)
+		if failure.Type == "NotFoundException" {
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, failure.Message)
+		}
+		return fmt.Errorf("KMS %s failed with %s: %s %s", operation, resp.Status, failure.Type, failure.Message)
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("KMS %s: malformed response: %v", operation, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package kms
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// newTestClient запускает Handler поверх локальной службы ключей с ключами keyIDs
+// и возвращает HTTPClient, подключенный к ней
+func newTestClient(t *testing.T, keyIDs ...string) *HTTPClient {
+	t.Helper()
+
+	var records bytes.Buffer
+	for _, keyID := range keyIDs {
+		key := make([]byte, DataKeySize)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		records.WriteString(keyID + "," + base64.StdEncoding.EncodeToString(key) + "\n")
+	}
+	path := filepath.Join(t.TempDir(), LocalKeysFileName)
+	if err := os.WriteFile(path, records.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	local, err := LoadLocal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(Handler(local))
+	t.Cleanup(server.Close)
+	return NewHTTPClient(server.URL)
+}
+
+func TestHTTPClientListKeys(t *testing.T) {
+	client := newTestClient(t, "billing", "archive")
+
+	keys, err := client.ListKeys()
+	if err != nil {
+		t.Fatalf("ListKeys: %v", err)
+	}
+	// Порядок ключей совпадает с порядком файла: первый ключ выбирается по умолчанию
+	if want := []string{"billing", "archive"}; !slices.Equal(keys, want) {
+		t.Fatalf("ListKeys = %v, want %v", keys, want)
+	}
+}
+
+func TestHTTPClientGenerateAndDecrypt(t *testing.T) {
+	client := newTestClient(t, "billing", "archive")
+
+	plaintext, ciphertext, err := client.GenerateDataKey("archive")
+	if err != nil {
+		t.Fatalf("GenerateDataKey: %v", err)
+	}
+	if len(plaintext) != DataKeySize {
+		t.Fatalf("data key is %d bytes, want %d", len(plaintext), DataKeySize)
+	}
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Fatal("encrypted data key contains the plaintext key")
+	}
+
+	decrypted, err := client.Decrypt("archive", ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("Decrypt returned a different data key")
+	}
+
+	// Ключ данных, зашифрованный одним ключом, не расшифровывается другим
+	if _, err := client.Decrypt("billing", ciphertext); err == nil {
+		t.Fatal("Decrypt with another KMS key succeeded")
+	} else if errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Decrypt with another KMS key: got ErrKeyNotFound for an existing key: %v", err)
+	}
+}
+
+func TestHTTPClientKeyNotFound(t *testing.T) {
+	client := newTestClient(t, "billing")
+
+	// NotFoundException службы ключей превращается в ErrKeyNotFound
+	if _, _, err := client.GenerateDataKey("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GenerateDataKey(missing) = %v, want ErrKeyNotFound", err)
+	}
+
+	_, ciphertext, err := client.GenerateDataKey("billing")
+	if err != nil {
+		t.Fatalf("GenerateDataKey: %v", err)
+	}
+	if _, err := client.Decrypt("missing", ciphertext); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Decrypt(missing) = %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,22 @@
+package kms
+
+import "errors"
+
+// DataKeySize — размер ключей данных, которые выдает служба ключей (AES-256)
+const DataKeySize = 32
+
+// ErrKeyNotFound означает, что служба ключей не знает запрошенный ключ
+var ErrKeyNotFound = errors.New("KMS key not found")
+
+// KMS — служба ключей для конвертного шифрования объектов (aws:kms).
+// Ключи KMS не покидают службу: хранилище получает ключ данных в открытом виде
+// и в зашифрованном, сохраняет только зашифрованный и расшифровывает его при чтении.
+type KMS interface {
+	// GenerateDataKey создает ключ данных под ключом keyID и возвращает его
+	// в открытом виде и зашифрованным
+	GenerateDataKey(keyID string) (plaintext, ciphertext []byte, err error)
+	// Decrypt расшифровывает ключ данных, созданный GenerateDataKey под ключом keyID
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+	// ListKeys возвращает идентификаторы доступных ключей
+	ListKeys() ([]string, error)
+}
@@ -0,0 +1,148 @@
+package kms
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LocalKeysFileName — файл ключей локальной службы ключей в каталоге данных по умолчанию
+const LocalKeysFileName = "kms.keys"
+
+// Local — служба ключей на основе файла со строками "KeyID,ключ в base64".
+// Ключи читаются при запуске; новый ключ добавляется дописыванием строки в файл и перезапуском.
+type Local struct {
+	keys map[string][]byte
+	// order — идентификаторы ключей в порядке файла
+	order []string
+}
+
+// LoadLocal читает ключи из файла. Если файла нет, он создается с одним новым ключом.
+func LoadLocal(path string) (*Local, error) {
+	local := &Local{keys: make(map[string][]byte)}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		if err := local.create(path); err != nil {
+			return nil, err
+		}
+		return local, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error opening KMS key file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading KMS key file: %v", err)
+	}
+	for _, record := range records {
+		if len(record) < 2 || record[0] == "" {
+			return nil, fmt.Errorf("malformed KMS key record")
+		}
+		key, err := base64.StdEncoding.DecodeString(record[1])
+		if err != nil || len(key) != DataKeySize {
+			return nil, fmt.Errorf("KMS key %s must be %d bytes in base64", record[0], DataKeySize)
+		}
+		if _, exists := local.keys[record[0]]; exists {
+			return nil, fmt.Errorf("duplicate KMS key %s", record[0])
+		}
+		local.keys[record[0]] = key
+		local.order = append(local.order, record[0])
+	}
+	return local, nil
+}
+
+// GenerateDataKey создает ключ данных и шифрует его ключом keyID (AES-GCM, nonce|шифртекст)
+func (l *Local) GenerateDataKey(keyID string) ([]byte, []byte, error) {
+	aead, err := l.cipher(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext := make([]byte, DataKeySize)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, fmt.Errorf("unable to generate data key: %v", err)
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("unable to generate data key: %v", err)
+	}
+	return plaintext, aead.Seal(nonce, nonce, plaintext, []byte(keyID)), nil
+}
+
+// Decrypt расшифровывает ключ данных, зашифрованный ключом keyID
+func (l *Local) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	aead, err := l.cipher(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed data key ciphertext")
+	}
+	plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt data key with KMS key %s: %v", keyID, err)
+	}
+	return plaintext, nil
+}
+
+// ListKeys возвращает идентификаторы ключей в порядке файла
+func (l *Local) ListKeys() ([]string, error) {
+	return append([]string(nil), l.order...), nil
+}
+
+func (l *Local) cipher(keyID string) (cipher.AEAD, error) {
+	key, ok := l.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMS key %s: %v", keyID, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// create создает файл ключей с одним новым ключом со случайным идентификатором
+func (l *Local) create(path string) error {
+	key := make([]byte, DataKeySize)
+	id := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("unable to generate KMS key: %v", err)
+	}
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("unable to generate KMS key: %v", err)
+	}
+	keyID := hex.EncodeToString(id)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error creating KMS key directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("error creating KMS key file: %v", err)
+	}
+	writer := csv.NewWriter(file)
+	writer.Write([]string{keyID, base64.StdEncoding.EncodeToString(key)})
+	writer.Flush()
+	err = writer.Error()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("error writing KMS key file: %v", err)
+	}
+
+	l.keys[keyID] = key
+	l.order = []string{keyID}
+	return nil
+}
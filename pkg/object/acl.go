@@ -0,0 +1,177 @@
+package object
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"triple-s/pkg/acl"
+	"triple-s/pkg/auth"
+	"triple-s/pkg/s3error"
+)
+
+// maxACLBodySize ограничивает размер тела PutBucketAcl и PutObjectAcl
+const maxACLBodySize = 64 << 10
+
+// GetBucketAclHandler возвращает ACL ведра (GET /{bucket}?acl)
+func GetBucketAclHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	bucketACL, err := acl.LoadBucket(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	s3error.WriteXML(w, r, http.StatusOK, bucketACL.Policy())
+}
+
+// PutBucketAclHandler заменяет ACL ведра стандартным ACL из x-amz-acl
+// или документом AccessControlPolicy из тела запроса (PUT /{bucket}?acl)
+func PutBucketAclHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	build, err := aclFromRequest(r)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// Владелец ведра не меняется при замене ACL
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+	current, err := acl.LoadBucket(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if err := acl.SaveBucket(bucketDir, bucketName, build(current.Owner)); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetObjectAclHandler возвращает ACL версии объекта (GET /{bucket}/{key}?acl)
+func GetObjectAclHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	record, _, err := lookupVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"))
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if record.VersionID != "" {
+		w.Header().Set("x-amz-version-id", record.VersionID)
+	}
+	objectACL, err := withBucketOwner(bucketDir, bucketName, record.ACL)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	s3error.WriteXML(w, r, http.StatusOK, objectACL.Policy())
+}
+
+// PutObjectAclHandler заменяет ACL версии объекта (PUT /{bucket}/{key}?acl)
+func PutObjectAclHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	build, err := aclFromRequest(r)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	record, err := updateObjectVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"), func(record *objectRecord) error {
+		current, err := withBucketOwner(bucketDir, bucketName, record.ACL)
+		if err != nil {
+			return err
+		}
+		record.ACL = build(current.Owner)
+		return nil
+	})
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if record.VersionID != "" {
+		w.Header().Set("x-amz-version-id", record.VersionID)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// LookupObjectACL возвращает ACL версии объекта; пустой versionID означает текущую версию.
+// Объектом без владельца владеет владелец ведра.
+func LookupObjectACL(bucketDir, bucketName, objectKey, versionID string) (acl.ACL, error) {
+	record, _, err := lookupVersion(bucketDir, bucketName, objectKey, versionID)
+	if err != nil {
+		return acl.ACL{}, err
+	}
+	return withBucketOwner(bucketDir, bucketName, record.ACL)
+}
+
+// aclFromRequest проверяет новый ACL из заголовка x-amz-acl или тела запроса
+// и возвращает функцию, которая строит его для владельца ресурса
+func aclFromRequest(r *http.Request) (func(owner string) acl.ACL, error) {
+	for name := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-grant-") {
+			return nil, s3error.ErrNotImplemented.WithMessage("x-amz-grant-* headers are not supported, use x-amz-acl or an AccessControlPolicy body")
+		}
+	}
+
+	if canned := r.Header.Get("x-amz-acl"); canned != "" {
+		if r.ContentLength > 0 {
+			return nil, s3error.ErrInvalidRequest.WithMessage("a canned ACL and an AccessControlPolicy body cannot be specified together")
+		}
+		if _, err := acl.Canned(canned, ""); err != nil {
+			return nil, err
+		}
+		return func(owner string) acl.ACL {
+			built, _ := acl.Canned(canned, owner)
+			return built
+		}, nil
+	}
+
+	var policy acl.AccessControlPolicy
+	if err := readXMLBody(r, maxACLBodySize, &policy); err != nil {
+		return nil, err
+	}
+	if _, err := acl.FromPolicy(policy, ""); err != nil {
+		return nil, err
+	}
+	return func(owner string) acl.ACL {
+		built, _ := acl.FromPolicy(policy, owner)
+		return built
+	}, nil
+}
+
+// requestOwner возвращает владельца создаваемого объекта: ключ доступа отправителя,
+// а для анонимного запроса — владельца ведра
+func requestOwner(r *http.Request, bucketDir, bucketName string) string {
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok && !identity.Anonymous() {
+		return identity.AccessKey
+	}
+	bucketACL, err := acl.LoadBucket(bucketDir, bucketName)
+	if err != nil {
+		return ""
+	}
+	return bucketACL.Owner
+}
+
+// withBucketOwner возвращает ACL объекта, у которого нет владельца, с владельцем ведра.
+// Владельца нет у объектов, записанных до появления ACL или анонимно до этой проверки.
+func withBucketOwner(bucketDir, bucketName string, objectACL acl.ACL) (acl.ACL, error) {
+	if objectACL.Owner != "" {
+		return objectACL, nil
+	}
+	bucketACL, err := acl.LoadBucket(bucketDir, bucketName)
+	if err != nil {
+		return acl.ACL{}, err
+	}
+	objectACL.Owner = bucketACL.Owner
+	return objectACL, nil
+}
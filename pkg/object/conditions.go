@@ -0,0 +1,94 @@
+package object
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"triple-s/pkg/s3error"
+)
+
+// conditions — условные заголовки запроса (If-Match, If-None-Match и т. д.)
+type conditions struct {
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   string
+	IfUnmodifiedSince string
+}
+
+// requestConditions извлекает условные заголовки запроса GET/HEAD
+func requestConditions(h http.Header) conditions {
+	return conditions{
+		IfMatch:           h.Get("If-Match"),
+		IfNoneMatch:       h.Get("If-None-Match"),
+		IfModifiedSince:   h.Get("If-Modified-Since"),
+		IfUnmodifiedSince: h.Get("If-Unmodified-Since"),
+	}
+}
+
+// copySourceConditions извлекает условия x-amz-copy-source-if-* запроса CopyObject
+func copySourceConditions(h http.Header) conditions {
+	return conditions{
+		IfMatch:           h.Get("X-Amz-Copy-Source-If-Match"),
+		IfNoneMatch:       h.Get("X-Amz-Copy-Source-If-None-Match"),
+		IfModifiedSince:   h.Get("X-Amz-Copy-Source-If-Modified-Since"),
+		IfUnmodifiedSince: h.Get("X-Amz-Copy-Source-If-Unmodified-Since"),
+	}
+}
+
+// check проверяет условия по метаданным объекта в порядке RFC 7232.
+// notModified == true означает, что сработали If-None-Match или If-Modified-Since;
+// невыполненные If-Match и If-Unmodified-Since возвращают PreconditionFailed.
+func (c conditions) check(record objectRecord) (notModified bool, err error) {
+	modified, parseErr := time.Parse(time.RFC3339, record.LastModified)
+	if parseErr != nil {
+		modified = time.Time{}
+	}
+
+	if c.IfMatch != "" {
+		if !etagMatches(c.IfMatch, record.ETag) {
+			return false, s3error.ErrPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(c.IfUnmodifiedSince); c.IfUnmodifiedSince != "" && err == nil {
+		if modified.After(since) {
+			return false, s3error.ErrPreconditionFailed
+		}
+	}
+
+	if c.IfNoneMatch != "" {
+		if etagMatches(c.IfNoneMatch, record.ETag) {
+			return true, nil
+		}
+	} else if since, err := http.ParseTime(c.IfModifiedSince); c.IfModifiedSince != "" && err == nil {
+		if !modified.After(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// etagMatches сравнивает список ETag из условного заголовка с ETag объекта
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if etag != "" && strings.Trim(candidate, `"`) == strings.Trim(etag, `"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified отправляет ответ 304 с валидаторами объекта
+func writeNotModified(w http.ResponseWriter, record objectRecord) {
+	if record.ETag != "" {
+		w.Header().Set("ETag", record.ETag)
+	}
+	if modified, err := time.Parse(time.RFC3339, record.LastModified); err == nil {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
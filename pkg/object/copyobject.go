@@ -0,0 +1,197 @@
+package object
+
+import (
+	"crypto/md5"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"triple-s/pkg/acl"
+	"triple-s/pkg/s3error"
+)
+
+// CopyObjectResult — ответ CopyObject
+type CopyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+// CopyObjectHandler копирует объект на стороне сервера (PUT с заголовком x-amz-copy-source)
+func CopyObjectHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	if bucketName == "" || objectKey == "" {
+		s3error.WriteError(w, r, s3error.ErrInvalidURI.WithMessage("missing bucket name or object key"))
+		return
+	}
+
+	// 1. Разбор источника копирования
+	srcBucket, srcKey, srcVersionID, err := parseCopySource(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// 2. Проверка ведра назначения и ключа
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+	if err := validateObjectKey(objectKey); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// 3. Поиск исходного объекта и проверка условий x-amz-copy-source-if-*
+	source, srcFile, err := openVersion(bucketDir, srcBucket, srcKey, srcVersionID)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	defer srcFile.Close()
+	if notModified, err := copySourceConditions(r.Header).check(source); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	} else if notModified {
+		s3error.WriteError(w, r, s3error.ErrPreconditionFailed)
+		return
+	}
+
+	// 4. Выбор метаданных: COPY (по умолчанию) берет их из источника, REPLACE — из запроса
+	contentType, metadata := source.ContentType, source.Metadata
+	switch directive := r.Header.Get("X-Amz-Metadata-Directive"); directive {
+	case "", "COPY":
+		// Копирование предыдущей версии поверх ключа допустимо: так версия восстанавливается,
+		// как и копирование на себя с изменением шифрования
+		if srcBucket == bucketName && srcKey == objectKey && srcVersionID == "" && !changesEncryption(r.Header) {
+			s3error.WriteError(w, r, s3error.ErrInvalidRequest.WithMessage("This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes."))
+			return
+		}
+	case "REPLACE":
+		contentType = defaultContentType(objectKey, r.Header.Get("Content-Type"))
+		if metadata, err = extractMetadata(r.Header); err != nil {
+			s3error.WriteError(w, r, err)
+			return
+		}
+	default:
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("unknown metadata directive: "+directive))
+		return
+	}
+
+	// 5. Выбор тегов: COPY (по умолчанию) берет их из источника, REPLACE — из x-amz-tagging
+	tags := source.Tags
+	switch directive := r.Header.Get("X-Amz-Tagging-Directive"); directive {
+	case "", "COPY":
+	case "REPLACE":
+		if tags, err = parseTaggingHeader(r.Header.Get("x-amz-tagging")); err != nil {
+			s3error.WriteError(w, r, err)
+			return
+		}
+	default:
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("unknown tagging directive: "+directive))
+		return
+	}
+
+	// ACL источника не копируется: копия получает ACL из x-amz-acl, по умолчанию private
+	objectACL, err := acl.Canned(r.Header.Get("x-amz-acl"), requestOwner(r, bucketDir, bucketName))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// Шифрование источника не копируется: копия шифруется по x-amz-server-side-encryption*
+	// или шифрованию ведра назначения по умолчанию. Источник SSE-C читается ключом
+	// из x-amz-copy-source-server-side-encryption-customer-*.
+	encryption, err := requestEncryption(r, bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// Удержание источника не копируется: копия получает удержание из x-amz-object-lock-*
+	// или удержание ведра назначения по умолчанию
+	lock, err := requestObjectLock(r, bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// 6. Копирование открытых данных через временный файл
+	sourceKey, err := readKey(r.Header, copySourceKeyHeaderPrefix, source.Encryption)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	srcData, err := decryptReader(srcFile, source.Encryption, sourceKey, 0)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	envelope, key, err := newEnvelope(encryption)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	hash := md5.New()
+	tmpPath, size, err := writeObjectData(bucketDir, srcData, envelope, key, hash)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	etag, err := objectETag(envelope, hash.Sum(nil))
+	if err != nil {
+		os.Remove(tmpPath)
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// 7. Сохранение копии как новой версии объекта назначения
+	record, err := putObject(bucketDir, bucketName, tmpPath, objectRecord{
+		Key:          objectKey,
+		Size:         size,
+		ContentType:  contentType,
+		LastModified: time.Now().Format(time.RFC3339),
+		ETag:         etag,
+		Metadata:     metadata,
+		Tags:         tags,
+		ACL:          objectACL,
+		Encryption:   envelope,
+		Lock:         lock,
+	}, false)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	if source.VersionID != "" {
+		w.Header().Set("x-amz-copy-source-version-id", source.VersionID)
+	}
+	if record.VersionID != "" {
+		w.Header().Set("x-amz-version-id", record.VersionID)
+	}
+	setEncryptionHeaders(w, r, envelope)
+	s3error.WriteXML(w, r, http.StatusOK, CopyObjectResult{LastModified: record.LastModified, ETag: record.ETag})
+}
+
+// parseCopySource разбирает x-amz-copy-source вида "/bucket/key", "bucket/key" или "bucket/key?versionId=ID"
+func parseCopySource(header string) (string, string, string, error) {
+	// versionId отделяется до декодирования: в закодированном ключе '?' записан как %3F
+	header, versionID, _ := strings.Cut(header, "?versionId=")
+	source, err := url.PathUnescape(header)
+	if err != nil {
+		return "", "", "", s3error.ErrInvalidArgument.WithMessage("x-amz-copy-source is not URL-encoded correctly")
+	}
+	srcBucket, srcKey, found := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !found || srcBucket == "" || srcBucket == "." || srcBucket == ".." || strings.Contains(srcBucket, `\`) {
+		return "", "", "", s3error.ErrInvalidArgument.WithMessage("copy source must be of the form bucket/key")
+	}
+	if err := validateObjectKey(srcKey); err != nil {
+		return "", "", "", err
+	}
+	return srcBucket, srcKey, versionID, nil
+}
@@ -0,0 +1,283 @@
+package object
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"triple-s/pkg/s3error"
+)
+
+const (
+	corsFileName = "cors.xml"
+	maxCORSRules = 100
+	// maxCORSBodySize ограничивает размер тела PutBucketCors
+	maxCORSBodySize = 64 << 10
+)
+
+// corsMethods — методы, которые можно разрешить в правиле CORS
+var corsMethods = []string{http.MethodGet, http.MethodPut, http.MethodHead, http.MethodPost, http.MethodDelete}
+
+// CORSConfiguration — тело PutBucketCors и ответ GetBucketCors
+type CORSConfiguration struct {
+	XMLName xml.Name
+	Rules   []CORSRule `xml:"CORSRule"`
+}
+
+// CORSRule — одно правило CORS
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  *int     `xml:"MaxAgeSeconds"`
+}
+
+// PutBucketCorsHandler сохраняет правила CORS ведра (PUT /{bucket}?cors)
+func PutBucketCorsHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	var config CORSConfiguration
+	if err := readXMLBody(r, maxCORSBodySize, &config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if err := validateCORS(config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	config.XMLName = xml.Name{Local: "CORSConfiguration"}
+	data, err := xml.Marshal(config)
+	if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to encode CORS configuration: %v", err))
+		return
+	}
+	if err := writeBucketConfig(bucketDir, bucketName, corsFileName, data); err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to save CORS configuration: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketCorsHandler возвращает правила CORS ведра (GET /{bucket}?cors)
+func GetBucketCorsHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	config, found, err := loadCORS(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !found {
+		s3error.WriteError(w, r, s3error.ErrNoSuchCORSConfig)
+		return
+	}
+	config.XMLName = s3error.XMLName("CORSConfiguration")
+	s3error.WriteXML(w, r, http.StatusOK, config)
+}
+
+// DeleteBucketCorsHandler удаляет правила CORS ведра (DELETE /{bucket}?cors)
+func DeleteBucketCorsHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	if err := os.Remove(filepath.Join(bucketPath, corsFileName)); err != nil && !os.IsNotExist(err) {
+		s3error.WriteError(w, r, fmt.Errorf("unable to delete CORS configuration: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CORSPreflightHandler отвечает на предварительный запрос браузера (OPTIONS /{bucket}[/{key}])
+// по первому правилу, которое разрешает источник, метод и все запрошенные заголовки
+func CORSPreflightHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	if origin == "" {
+		s3error.WriteError(w, r, s3error.ErrInvalidRequest.WithMessage("Insufficient information. Origin request header needed."))
+		return
+	}
+	if method == "" {
+		s3error.WriteError(w, r, s3error.ErrInvalidRequest.WithMessage("Invalid Access-Control-Request-Method: null"))
+		return
+	}
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	config, found, err := loadCORS(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !found {
+		s3error.WriteError(w, r, s3error.ErrAccessForbidden.WithMessage("CORSResponse: CORS is not enabled for this bucket."))
+		return
+	}
+
+	var requestHeaders []string
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			requestHeaders = append(requestHeaders, strings.ToLower(header))
+		}
+	}
+	w.Header().Add("Vary", "Origin, Access-Control-Request-Headers, Access-Control-Request-Method")
+	rule, found := config.match(origin, method, requestHeaders)
+	if !found {
+		s3error.WriteError(w, r, s3error.ErrAccessForbidden)
+		return
+	}
+
+	setCORSRuleHeaders(w, rule, origin)
+	if len(requestHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+	}
+	if rule.MaxAgeSeconds != nil {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(*rule.MaxAgeSeconds))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// SetCORSHeaders добавляет заголовки Access-Control-* к ответу на запрос с заголовком Origin,
+// если правила CORS ведра разрешают его источник и метод
+func SetCORSHeaders(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	config, found, err := loadCORS(bucketDir, bucketName)
+	if err != nil || !found {
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+	if rule, found := config.match(origin, r.Method, nil); found {
+		setCORSRuleHeaders(w, rule, origin)
+	}
+}
+
+// setCORSRuleHeaders устанавливает общие для предварительного и обычного запроса заголовки
+func setCORSRuleHeaders(w http.ResponseWriter, rule CORSRule, origin string) {
+	// Правило для любого источника разрешает запросы без учетных данных
+	if containsString(rule.AllowedOrigins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+	if len(rule.ExposeHeaders) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+}
+
+// loadCORS читает сохраненную конфигурацию CORS ведра
+func loadCORS(bucketDir, bucketName string) (CORSConfiguration, bool, error) {
+	data, err := os.ReadFile(filepath.Join(bucketDir, bucketName, corsFileName))
+	if os.IsNotExist(err) {
+		return CORSConfiguration{}, false, nil
+	} else if err != nil {
+		return CORSConfiguration{}, false, fmt.Errorf("unable to read CORS configuration: %v", err)
+	}
+	var config CORSConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return CORSConfiguration{}, false, fmt.Errorf("malformed CORS configuration: %v", err)
+	}
+	return config, true, nil
+}
+
+// validateCORS проверяет правила CORS перед сохранением
+func validateCORS(config CORSConfiguration) error {
+	if len(config.Rules) == 0 || len(config.Rules) > maxCORSRules {
+		return s3error.ErrMalformedXML.WithMessage("a CORS configuration must contain between 1 and 100 rules")
+	}
+	for _, rule := range config.Rules {
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 {
+			return s3error.ErrMalformedXML.WithMessage("a CORS rule must have at least one AllowedOrigin and AllowedMethod")
+		}
+		for _, method := range rule.AllowedMethods {
+			if !containsString(corsMethods, method) {
+				return s3error.ErrInvalidRequest.WithMessage("Found unsupported HTTP method in CORS config. Unsupported method is " + method)
+			}
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if strings.Count(origin, "*") > 1 {
+				return s3error.ErrInvalidRequest.WithMessage(`AllowedOrigin "` + origin + `" can not have more than one wildcard.`)
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(header, "*") > 1 {
+				return s3error.ErrInvalidRequest.WithMessage(`AllowedHeader "` + header + `" can not have more than one wildcard.`)
+			}
+		}
+		if rule.MaxAgeSeconds != nil && *rule.MaxAgeSeconds < 0 {
+			return s3error.ErrMalformedXML.WithMessage("MaxAgeSeconds must be a non-negative integer")
+		}
+	}
+	return nil
+}
+
+// match возвращает первое правило, разрешающее источник, метод и заголовки запроса
+func (config CORSConfiguration) match(origin, method string, headers []string) (CORSRule, bool) {
+	for _, rule := range config.Rules {
+		if !containsString(rule.AllowedMethods, method) || !matchesAnyPattern(rule.AllowedOrigins, origin, false) {
+			continue
+		}
+		allowed := true
+		for _, header := range headers {
+			if !matchesAnyPattern(rule.AllowedHeaders, header, true) {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			return rule, true
+		}
+	}
+	return CORSRule{}, false
+}
+
+// matchesAnyPattern сравнивает значение с шаблонами, в которых '*' заменяет любую подстроку
+func matchesAnyPattern(patterns []string, value string, ignoreCase bool) bool {
+	if ignoreCase {
+		value = strings.ToLower(value)
+	}
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern = strings.ToLower(pattern)
+		}
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		if !wildcard && value == pattern {
+			return true
+		}
+		if wildcard && len(value) >= len(prefix)+len(suffix) && strings.HasPrefix(value, prefix) && strings.HasSuffix(value, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString сообщает, есть ли s среди values
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
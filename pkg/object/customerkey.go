@@ -0,0 +1,92 @@
+package object
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"triple-s/pkg/s3error"
+	"triple-s/pkg/sse"
+)
+
+const (
+	// customerKeyHeaderPrefix — заголовки ключа клиента (SSE-C) для записываемого или читаемого объекта
+	customerKeyHeaderPrefix = "x-amz-server-side-encryption-customer-"
+	// copySourceKeyHeaderPrefix — заголовки ключа клиента для источника копирования
+	copySourceKeyHeaderPrefix = "x-amz-copy-source-server-side-encryption-customer-"
+)
+
+// parseCustomerKey читает ключ клиента из заголовков prefix+algorithm, prefix+key и prefix+key-MD5.
+// nil без ошибки означает, что заголовков нет.
+func parseCustomerKey(header http.Header, prefix string) ([]byte, error) {
+	algorithm, encoded, keyMD5 := header.Get(prefix+"algorithm"), header.Get(prefix+"key"), header.Get(prefix+"key-MD5")
+	if algorithm == "" && encoded == "" && keyMD5 == "" {
+		return nil, nil
+	}
+	switch {
+	case algorithm == "":
+		return nil, s3error.ErrInvalidArgument.WithMessage("Requests specifying Server Side Encryption with Customer provided keys must provide a valid encryption algorithm.")
+	case algorithm != sse.AlgorithmAES256:
+		return nil, s3error.ErrInvalidEncryptionAlg
+	case encoded == "":
+		return nil, s3error.ErrInvalidArgument.WithMessage("Requests specifying Server Side Encryption with Customer provided keys must provide an appropriate secret key.")
+	case keyMD5 == "":
+		return nil, s3error.ErrInvalidArgument.WithMessage("Requests specifying Server Side Encryption with Customer provided keys must provide the client calculated MD5 of the secret key.")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != sse.KeySize {
+		return nil, s3error.ErrInvalidArgument.WithMessage("The secret key was invalid for the specified algorithm.")
+	}
+	if digest := md5.Sum(key); base64.StdEncoding.EncodeToString(digest[:]) != keyMD5 {
+		return nil, s3error.ErrInvalidArgument.WithMessage("The calculated MD5 hash of the key did not match the hash that was provided.")
+	}
+	return key, nil
+}
+
+// objectETag возвращает ETag записанных данных по MD5 открытых данных. Для данных,
+// зашифрованных ключом клиента, ETag случайный: по MD5 любой с правом чтения мог бы
+// проверять догадки о содержимом, а в S3 ETag таких объектов не равен MD5.
+func objectETag(envelope sse.Envelope, digest []byte) (string, error) {
+	if envelope.CustomerKey() {
+		digest = make([]byte, md5.Size)
+		if _, err := rand.Read(digest); err != nil {
+			return "", fmt.Errorf("unable to generate ETag: %v", err)
+		}
+	}
+	return `"` + hex.EncodeToString(digest) + `"`, nil
+}
+
+// readKey возвращает ключ для чтения версии объекта: ключ клиента из заголовков с префиксом prefix
+// для SSE-C или ключ данных, обернутый мастер-ключом. nil означает открытые данные.
+func readKey(header http.Header, prefix string, envelope sse.Envelope) ([]byte, error) {
+	customerKey, err := parseCustomerKey(header, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if envelope.CustomerKey() {
+		if customerKey == nil {
+			return nil, s3error.ErrInvalidRequest.WithMessage("The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.")
+		}
+		if !sse.MatchFingerprint(envelope.KeyFingerprint, customerKey) {
+			return nil, s3error.ErrAccessDenied
+		}
+		return customerKey, nil
+	}
+	if customerKey != nil {
+		return nil, s3error.ErrInvalidRequest.WithMessage("The encryption parameters are not applicable to this object.")
+	}
+	if !envelope.Encrypted() {
+		return nil, nil
+	}
+	return dataKey(envelope)
+}
+
+// changesEncryption сообщает, что запрос копирования задает шифрование копии:
+// такая копия объекта на себя допустима
+func changesEncryption(header http.Header) bool {
+	return header.Get("x-amz-server-side-encryption") != "" || header.Get(customerKeyHeaderPrefix+"algorithm") != ""
+}
@@ -1,88 +1,46 @@
 package object
 
 import (
-	"encoding/csv"
-	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+
+	"triple-s/pkg/s3error"
 )
 
 // DeleteObjectHandler обрабатывает удаление объекта из бакета.
 func DeleteObjectHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
 	if bucketName == "" || objectKey == "" {
-		http.Error(w, "400 Bad Request: Missing bucket name or object key", http.StatusBadRequest)
+		s3error.WriteError(w, r, s3error.ErrInvalidURI.WithMessage("missing bucket name or object key"))
 		return
 	}
 
 	// 2. Проверка существования ведра
 	bucketPath := filepath.Join(bucketDir, bucketName)
 	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
-		http.Error(w, "404 Not Found: Bucket does not exist", http.StatusNotFound)
-		return
-	}
-
-	// 3. Проверка существования объекта
-	objectPath := filepath.Join(bucketPath, objectKey)
-	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-		http.Error(w, "404 Not Found: Object does not exist", http.StatusNotFound)
-		return
-	}
-
-	// 4. Удаление объекта
-	if err := os.Remove(objectPath); err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to delete object", http.StatusInternalServerError)
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
 		return
 	}
 
-	// 5. Обновление метаданных в CSVobjectCSVPath := fmt.Sprintf("data/%s/objects.csv", bucketName)
-	if err := deleteObjectMetadata(bucketDir, bucketName, objectKey); err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to update metadata", http.StatusInternalServerError)
-	}
-
-	// 6. Возвращаем успешный ответ
-	w.WriteHeader(http.StatusNoContent) // 204 No Content
-}
-
-// deleteObjectMetadata обновляет CSV файл, удаляя запись о объекте
-func deleteObjectMetadata(bucketDir, bucketName, objectKey string) error {
-	objectCSVPath := fmt.Sprintf("%s/%s/objects.csv", bucketDir, bucketName)
-
-	// Открываем файл метаданных объектов для чтения
-	file, err := os.Open(objectCSVPath)
+	// 3. Удаление объекта или версии; в версионируемом ведре без versionId создается маркер удаления.
+	// Версию под удержанием Object Lock удалить нельзя, GOVERNANCE снимается разрешенным обходом.
+	bypass, err := canBypassGovernance(r, bucketDir, bucketName, objectKey)
 	if err != nil {
-		return fmt.Errorf("unable to open objects metadata file: %v", err)
+		s3error.WriteError(w, r, err)
+		return
 	}
-	defer file.Close()
-
-	// Читаем все строки из CSV
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	outcome, err := removeObject(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"), bypass)
 	if err != nil {
-		return fmt.Errorf("unable to read objects metadata: %v", err)
-	}
-
-	// Записываем обратно в CSV все ведра, кроме удаляемого
-	var updatedRecords [][]string
-	for _, record := range records {
-		if record[0] != objectKey {
-			updatedRecords = append(updatedRecords, record)
-		}
+		s3error.WriteError(w, r, err)
+		return
 	}
 
-	file, err = os.OpenFile(objectCSVPath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0o644)
-	if err != nil {
-		return fmt.Errorf("error opening CSV file for writing: %v", err)
+	// 4. Возвращаем успешный ответ
+	if outcome.VersionID != "" {
+		w.Header().Set("x-amz-version-id", outcome.VersionID)
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	err = writer.WriteAll(updatedRecords)
-	if err != nil {
-		return fmt.Errorf("error writing to CSV file: %v", err)
+	if outcome.DeleteMarker {
+		w.Header().Set("x-amz-delete-marker", "true")
 	}
-
-	return nil
+	w.WriteHeader(http.StatusNoContent) // 204 No Content
 }
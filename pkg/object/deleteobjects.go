@@ -0,0 +1,174 @@
+package object
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"triple-s/pkg/policy"
+	"triple-s/pkg/s3error"
+)
+
+const (
+	// maxDeleteObjects — максимальное число ключей в одном запросе DeleteObjects
+	maxDeleteObjects = 1000
+	// maxDeleteObjectsBodySize ограничивает тело DeleteObjects: 1000 ключей по 1 КБ с разметкой
+	maxDeleteObjectsBodySize = 2 << 20
+)
+
+// DeleteRequest — тело запроса DeleteObjects
+type DeleteRequest struct {
+	XMLName xml.Name           `xml:"Delete"`
+	Quiet   bool               `xml:"Quiet"`
+	Objects []ObjectIdentifier `xml:"Object"`
+}
+
+// ObjectIdentifier — ключ объекта в запросе DeleteObjects
+type ObjectIdentifier struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId"`
+}
+
+// DeleteResult — ответ DeleteObjects
+type DeleteResult struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []DeletedObject `xml:"Deleted"`
+	Errors  []DeleteError   `xml:"Error"`
+}
+
+// DeletedObject — успешно удаленный объект
+type DeletedObject struct {
+	Key                   string `xml:"Key"`
+	VersionID             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionID string `xml:"DeleteMarkerVersionId,omitempty"`
+}
+
+// DeleteError — ошибка удаления отдельного ключа
+type DeleteError struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId,omitempty"`
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message"`
+}
+
+// DeleteObjectsHandler удаляет несколько объектов одним запросом (POST /{bucket}?delete)
+func DeleteObjectsHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	// 1. Проверка существования ведра
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	// 2. Разбор списка ключей
+	var request DeleteRequest
+	if err := readXMLBody(r, maxDeleteObjectsBodySize, &request); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if len(request.Objects) == 0 || len(request.Objects) > maxDeleteObjects {
+		s3error.WriteError(w, r, s3error.ErrMalformedXML)
+		return
+	}
+
+	// 3. Ключи, удаление которых запрещено политикой ведра, сразу попадают в ошибки
+	objects, errs := request.Objects, []DeleteError(nil)
+	if check, ok := policy.CheckerFromContext(r.Context()); ok {
+		objects = nil
+		for _, object := range request.Objects {
+			action := "s3:DeleteObject"
+			if object.VersionID != "" {
+				action = "s3:DeleteObjectVersion"
+			}
+			if check(action, policy.ObjectARN(bucketName, object.Key)) == policy.Deny {
+				errs = append(errs, newDeleteError(object, s3error.ErrAccessDenied))
+			} else {
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	// 4. Ключи, для которых разрешен обход удержания GOVERNANCE
+	bypass := make(map[string]bool)
+	for _, object := range objects {
+		allowed, err := canBypassGovernance(r, bucketDir, bucketName, object.Key)
+		if err != nil {
+			s3error.WriteError(w, r, err)
+			return
+		}
+		bypass[object.Key] = allowed
+	}
+
+	// 5. Удаление под одной блокировкой с одной перезаписью метаданных на весь пакет
+	deleted, deleteErrs, err := deleteObjects(bucketDir, bucketName, objects, bypass)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// В тихом режиме сообщаются только ошибки
+	result := DeleteResult{Errors: append(errs, deleteErrs...)}
+	if !request.Quiet {
+		result.Deleted = deleted
+	}
+
+	s3error.WriteXML(w, r, http.StatusOK, result)
+}
+
+// deleteObjects удаляет перечисленные объекты; отсутствующий объект считается удаленным.
+// bypass отмечает ключи, версии которых в режиме GOVERNANCE можно удалить.
+func deleteObjects(bucketDir, bucketName string, objects []ObjectIdentifier, bypass map[string]bool) ([]DeletedObject, []DeleteError, error) {
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+
+	idx, err := loadBucketIndex(bucketDir, bucketName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var deleted []DeletedObject
+	var errs []DeleteError
+	for _, object := range objects {
+		if err := validateObjectKey(object.Key); err != nil {
+			errs = append(errs, newDeleteError(object, err))
+			continue
+		}
+
+		var outcome deleteOutcome
+		if object.VersionID == "" {
+			outcome, err = idx.deleteCurrent(object.Key)
+		} else {
+			outcome, err = idx.deleteVersion(object.Key, object.VersionID, bypass[object.Key])
+		}
+		if err != nil && !errors.Is(err, s3error.ErrNoSuchKey) {
+			errs = append(errs, newDeleteError(object, err))
+			continue
+		}
+
+		entry := DeletedObject{Key: object.Key, VersionID: object.VersionID}
+		if outcome.DeleteMarker {
+			entry.DeleteMarker, entry.DeleteMarkerVersionID = true, outcome.VersionID
+		}
+		deleted = append(deleted, entry)
+	}
+
+	if err := idx.save(); err != nil {
+		return nil, nil, fmt.Errorf("unable to update metadata: %w", err)
+	}
+	return deleted, errs, nil
+}
+
+// newDeleteError преобразует ошибку удаления ключа в элемент <Error> ответа
+func newDeleteError(object ObjectIdentifier, err error) DeleteError {
+	var s3Err *s3error.Error
+	if !errors.As(err, &s3Err) {
+		log.Printf("delete %q: %v", object.Key, err)
+		s3Err = s3error.ErrInternalError
+	}
+	return DeleteError{Key: object.Key, VersionID: object.VersionID, Code: s3Err.Code, Message: s3Err.Message}
+}
@@ -0,0 +1,454 @@
+package object
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"triple-s/pkg/kms"
+	"triple-s/pkg/s3error"
+	"triple-s/pkg/sse"
+)
+
+const (
+	encryptionFileName = "encryption.xml"
+	// maxEncryptionBodySize ограничивает размер тела PutBucketEncryption
+	maxEncryptionBodySize = 16 << 10
+)
+
+// keyring — мастер-ключи шифрования объектов; задается при старте сервера
+var keyring *sse.Keyring
+
+// kmsService — служба ключей для шифрования aws:kms; задается при старте сервера
+var kmsService kms.KMS
+
+// SetKeyring задает мастер-ключи, которыми оборачиваются ключи данных объектов
+func SetKeyring(k *sse.Keyring) {
+	keyring = k
+}
+
+// SetKMS задает службу ключей, которая выдает ключи данных для aws:kms
+func SetKMS(k kms.KMS) {
+	kmsService = k
+}
+
+// ServerSideEncryptionConfiguration — тело PutBucketEncryption и ответ GetBucketEncryption
+type ServerSideEncryptionConfiguration struct {
+	XMLName xml.Name
+	Rules   []EncryptionRule `xml:"Rule"`
+}
+
+// EncryptionRule — правило шифрования по умолчанию
+type EncryptionRule struct {
+	ApplyServerSideEncryptionByDefault *EncryptionByDefault `xml:"ApplyServerSideEncryptionByDefault"`
+	BucketKeyEnabled                   bool                 `xml:"BucketKeyEnabled,omitempty"`
+}
+
+// EncryptionByDefault — алгоритм, которым шифруются объекты, загруженные без x-amz-server-side-encryption
+type EncryptionByDefault struct {
+	SSEAlgorithm   string `xml:"SSEAlgorithm"`
+	KMSMasterKeyID string `xml:"KMSMasterKeyID,omitempty"`
+}
+
+// PutBucketEncryptionHandler сохраняет шифрование ведра по умолчанию (PUT /{bucket}?encryption)
+func PutBucketEncryptionHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	var config ServerSideEncryptionConfiguration
+	if err := readXMLBody(r, maxEncryptionBodySize, &config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if len(config.Rules) != 1 || config.Rules[0].ApplyServerSideEncryptionByDefault == nil {
+		s3error.WriteError(w, r, s3error.ErrMalformedXML)
+		return
+	}
+	defaults := config.Rules[0].ApplyServerSideEncryptionByDefault
+	algorithm, err := parseEncryptionAlgorithm(defaults.SSEAlgorithm)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if defaults.KMSMasterKeyID != "" {
+		if algorithm != sse.AlgorithmKMS {
+			s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("KMSMasterKeyID is only allowed with the aws:kms algorithm"))
+			return
+		}
+		if err := checkKMSKey(defaults.KMSMasterKeyID); err != nil {
+			s3error.WriteError(w, r, err)
+			return
+		}
+	}
+
+	config.XMLName = xml.Name{Local: "ServerSideEncryptionConfiguration"}
+	data, err := xml.Marshal(config)
+	if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to encode encryption configuration: %v", err))
+		return
+	}
+	if err := writeBucketConfig(bucketDir, bucketName, encryptionFileName, data); err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to save encryption configuration: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketEncryptionHandler возвращает шифрование ведра по умолчанию (GET /{bucket}?encryption)
+func GetBucketEncryptionHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	config, found, err := loadBucketEncryption(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !found {
+		s3error.WriteError(w, r, s3error.ErrNoSuchEncryptionConfig)
+		return
+	}
+	config.XMLName = s3error.XMLName("ServerSideEncryptionConfiguration")
+	s3error.WriteXML(w, r, http.StatusOK, config)
+}
+
+// DeleteBucketEncryptionHandler удаляет шифрование ведра по умолчанию (DELETE /{bucket}?encryption).
+// Уже зашифрованные объекты остаются зашифрованными.
+func DeleteBucketEncryptionHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	if err := os.Remove(filepath.Join(bucketPath, encryptionFileName)); err != nil && !os.IsNotExist(err) {
+		s3error.WriteError(w, r, fmt.Errorf("unable to delete encryption configuration: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateMasterKey создает новый мастер-ключ и переоборачивает им ключи данных всех объектов,
+// версий и незавершенных загрузок; возвращает id нового ключа и число переобернутых ключей.
+// Данные объектов не перезаписываются. Прежний ключ удаляется, только если переобернуты
+// все ключи данных, поэтому прерванную ротацию можно безопасно повторить. Это операция
+// администратора: она выполняется при запуске сервера (-rotate-master-key) до приема запросов,
+// поэтому обработчики не держат ключи данных, которые она переоборачивает.
+func RotateMasterKey(dataDir string) (string, int, error) {
+	if keyring == nil {
+		return "", 0, fmt.Errorf("server-side encryption is not configured")
+	}
+
+	keyID, err := keyring.Rotate()
+	if err != nil {
+		return "", 0, err
+	}
+	rewrapped, err := rewrapDataKeys(dataDir)
+	if err != nil {
+		return "", rewrapped, fmt.Errorf("master key rotation is incomplete, retry it: %w", err)
+	}
+	if err := keyring.Retire(); err != nil {
+		return "", rewrapped, err
+	}
+	return keyID, rewrapped, nil
+}
+
+// rewrapDataKeys переоборачивает действующим мастер-ключом ключи данных во всех ведрах
+// и незавершенных загрузках и возвращает число измененных ключей
+func rewrapDataKeys(dataDir string) (int, error) {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read data directory: %v", err)
+	}
+
+	rewrapped := 0
+	rewrap := func(envelope *sse.Envelope) error {
+		// Ключи клиентов (SSE-C) и ключи службы ключей не обернуты мастер-ключом
+		if !envelope.MasterKeyWrapped() {
+			return nil
+		}
+		wrapped, changed, err := keyring.Rewrap(envelope.WrappedKey)
+		if err != nil {
+			return err
+		}
+		if changed {
+			envelope.WrappedKey = wrapped
+			rewrapped++
+		}
+		return nil
+	}
+
+	for _, entry := range entries {
+		if !isBucketDir(entry) {
+			continue
+		}
+		if err := rewrapBucket(dataDir, entry.Name(), rewrap); err != nil {
+			return rewrapped, err
+		}
+	}
+
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+	uploads, err := loadMultipartUploads(dataDir)
+	if err != nil {
+		return rewrapped, err
+	}
+	for i := range uploads {
+		if err := rewrap(&uploads[i].Encryption); err != nil {
+			return rewrapped, err
+		}
+	}
+	if len(uploads) == 0 {
+		return rewrapped, nil
+	}
+	return rewrapped, writeMultipartUploads(dataDir, uploads)
+}
+
+// rewrapBucket переоборачивает ключи данных текущих и предыдущих версий объектов ведра
+func rewrapBucket(dataDir, bucketName string, rewrap func(*sse.Envelope) error) error {
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+
+	idx, err := loadBucketIndex(dataDir, bucketName)
+	if err != nil {
+		return err
+	}
+	for i := range idx.current {
+		if err := rewrap(&idx.current[i].Encryption); err != nil {
+			return fmt.Errorf("%s/%s: %v", bucketName, idx.current[i].Key, err)
+		}
+	}
+	for i := range idx.versions {
+		if err := rewrap(&idx.versions[i].Encryption); err != nil {
+			return fmt.Errorf("%s/%s: %v", bucketName, idx.versions[i].Key, err)
+		}
+	}
+	idx.versionsChanged = len(idx.versions) > 0
+	return idx.save()
+}
+
+// loadBucketEncryption читает шифрование ведра по умолчанию
+func loadBucketEncryption(bucketDir, bucketName string) (ServerSideEncryptionConfiguration, bool, error) {
+	data, err := os.ReadFile(filepath.Join(bucketDir, bucketName, encryptionFileName))
+	if os.IsNotExist(err) {
+		return ServerSideEncryptionConfiguration{}, false, nil
+	} else if err != nil {
+		return ServerSideEncryptionConfiguration{}, false, fmt.Errorf("unable to read encryption configuration: %v", err)
+	}
+	var config ServerSideEncryptionConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return ServerSideEncryptionConfiguration{}, false, fmt.Errorf("malformed encryption configuration: %v", err)
+	}
+	return config, true, nil
+}
+
+// parseEncryptionAlgorithm проверяет значение x-amz-server-side-encryption
+func parseEncryptionAlgorithm(algorithm string) (string, error) {
+	switch algorithm {
+	case sse.AlgorithmAES256, sse.AlgorithmKMS:
+		return algorithm, nil
+	case "aws:kms:dsse":
+		return "", s3error.ErrNotImplemented.WithMessage("DSSE-KMS is not supported")
+	}
+	return "", s3error.ErrInvalidArgument.WithMessage("The encryption method specified is not supported")
+}
+
+// objectEncryption — шифрование, запрошенное для новой версии объекта
+type objectEncryption struct {
+	// algorithm — алгоритм шифрования; пустая строка означает открытые данные
+	algorithm string
+	// customerKey — ключ клиента (SSE-C); nil, если данные шифруются ключом хранилища
+	customerKey []byte
+	// kmsKeyID — ключ службы ключей для aws:kms
+	kmsKeyID string
+}
+
+// requestEncryption определяет шифрование новой версии объекта: ключ клиента из
+// x-amz-server-side-encryption-customer-*, алгоритм и ключ KMS из x-amz-server-side-encryption*
+// или шифрование ведра по умолчанию
+func requestEncryption(r *http.Request, bucketDir, bucketName string) (objectEncryption, error) {
+	customerKey, err := parseCustomerKey(r.Header, customerKeyHeaderPrefix)
+	if err != nil {
+		return objectEncryption{}, err
+	}
+	header := r.Header.Get("x-amz-server-side-encryption")
+	kmsKeyID := r.Header.Get("x-amz-server-side-encryption-aws-kms-key-id")
+	if customerKey != nil {
+		if header != "" {
+			return objectEncryption{}, s3error.ErrInvalidArgument.WithMessage("Server Side Encryption with Customer provided key is incompatible with the encryption method specified")
+		}
+		return objectEncryption{algorithm: sse.AlgorithmAES256, customerKey: customerKey}, nil
+	}
+
+	var encryption objectEncryption
+	if header != "" {
+		if encryption.algorithm, err = parseEncryptionAlgorithm(header); err != nil {
+			return objectEncryption{}, err
+		}
+		encryption.kmsKeyID = kmsKeyID
+	} else {
+		config, found, err := loadBucketEncryption(bucketDir, bucketName)
+		if err != nil || !found {
+			return objectEncryption{}, err
+		}
+		defaults := config.Rules[0].ApplyServerSideEncryptionByDefault
+		encryption = objectEncryption{algorithm: defaults.SSEAlgorithm, kmsKeyID: defaults.KMSMasterKeyID}
+	}
+
+	if kmsKeyID != "" && header != sse.AlgorithmKMS {
+		return objectEncryption{}, s3error.ErrInvalidArgument.WithMessage("x-amz-server-side-encryption-aws-kms-key-id is only allowed with x-amz-server-side-encryption: aws:kms")
+	}
+	if encryption.algorithm == sse.AlgorithmKMS && encryption.kmsKeyID == "" {
+		if encryption.kmsKeyID, err = defaultKMSKey(); err != nil {
+			return objectEncryption{}, err
+		}
+	}
+	return encryption, nil
+}
+
+// defaultKMSKey возвращает ключ службы ключей для aws:kms без явного ключа: первый из ListKeys
+func defaultKMSKey() (string, error) {
+	if kmsService == nil {
+		return "", s3error.ErrNotImplemented.WithMessage("no key management service is configured")
+	}
+	keys, err := kmsService.ListKeys()
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return "", s3error.ErrKMSNotFound.WithMessage("the key management service has no keys")
+	}
+	return keys[0], nil
+}
+
+// checkKMSKey проверяет, что служба ключей знает ключ keyID
+func checkKMSKey(keyID string) error {
+	if kmsService == nil {
+		return s3error.ErrNotImplemented.WithMessage("no key management service is configured")
+	}
+	keys, err := kmsService.ListKeys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key == keyID {
+			return nil
+		}
+	}
+	return s3error.ErrKMSNotFound.WithMessage("Invalid keyId " + keyID)
+}
+
+// newEnvelope создает Envelope и ключ данных для нового объекта. Без шифрования
+// возвращается пустой Envelope и nil-ключ: данные записываются открытыми.
+// Данные SSE-C шифруются ключом клиента, в Envelope попадает только его отпечаток.
+func newEnvelope(encryption objectEncryption) (sse.Envelope, []byte, error) {
+	if encryption.algorithm == "" {
+		return sse.Envelope{}, nil, nil
+	}
+	iv, err := sse.NewIV()
+	if err != nil {
+		return sse.Envelope{}, nil, err
+	}
+	if encryption.customerKey != nil {
+		fingerprint, err := sse.Fingerprint(encryption.customerKey)
+		if err != nil {
+			return sse.Envelope{}, nil, err
+		}
+		return sse.Envelope{Algorithm: encryption.algorithm, KeyFingerprint: fingerprint, IV: iv}, encryption.customerKey, nil
+	}
+	if encryption.algorithm == sse.AlgorithmKMS {
+		if kmsService == nil {
+			return sse.Envelope{}, nil, s3error.ErrNotImplemented.WithMessage("no key management service is configured")
+		}
+		plaintext, ciphertext, err := kmsService.GenerateDataKey(encryption.kmsKeyID)
+		if errors.Is(err, kms.ErrKeyNotFound) {
+			return sse.Envelope{}, nil, s3error.ErrKMSNotFound.WithMessage("Invalid keyId " + encryption.kmsKeyID)
+		} else if err != nil {
+			return sse.Envelope{}, nil, err
+		}
+		envelope := sse.Envelope{
+			Algorithm:  encryption.algorithm,
+			WrappedKey: base64.StdEncoding.EncodeToString(ciphertext),
+			KMSKeyID:   encryption.kmsKeyID,
+			IV:         iv,
+		}
+		return envelope, plaintext, nil
+	}
+	if keyring == nil {
+		return sse.Envelope{}, nil, s3error.ErrNotImplemented.WithMessage("server-side encryption is not configured")
+	}
+	dataKey, wrapped, err := keyring.GenerateDataKey()
+	if err != nil {
+		return sse.Envelope{}, nil, err
+	}
+	return sse.Envelope{Algorithm: encryption.algorithm, WrappedKey: wrapped, IV: iv}, dataKey, nil
+}
+
+// dataKey расшифровывает ключ данных версии объекта мастер-ключом или службой ключей
+func dataKey(envelope sse.Envelope) ([]byte, error) {
+	if envelope.KMSKeyID != "" {
+		if kmsService == nil {
+			return nil, fmt.Errorf("object is encrypted with KMS key %s, but no key management service is configured", envelope.KMSKeyID)
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(envelope.WrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("malformed KMS data key: %v", err)
+		}
+		return kmsService.Decrypt(envelope.KMSKeyID, ciphertext)
+	}
+	if keyring == nil {
+		return nil, fmt.Errorf("object is encrypted, but no master key is configured")
+	}
+	return keyring.Unwrap(envelope.WrappedKey)
+}
+
+// writeObjectData записывает тело во временный файл, шифруя его ключом key, если он задан.
+// writers получают открытые данные (например, для подсчета MD5).
+func writeObjectData(bucketDir string, body io.Reader, envelope sse.Envelope, key []byte, writers ...io.Writer) (string, int64, error) {
+	if key == nil {
+		return writeTempObject(bucketDir, body, writers...)
+	}
+	stream, err := sse.NewStream(key, envelope.IV, 0)
+	if err != nil {
+		return "", 0, err
+	}
+	plain := io.TeeReader(body, io.MultiWriter(writers...))
+	return writeTempObject(bucketDir, cipher.StreamReader{S: stream, R: plain})
+}
+
+// decryptReader возвращает открытые данные версии объекта; data читает файл с позиции offset,
+// key — ключ, полученный от readKey (nil для открытых данных)
+func decryptReader(data io.Reader, envelope sse.Envelope, key []byte, offset int64) (io.Reader, error) {
+	if key == nil {
+		return data, nil
+	}
+	stream, err := sse.NewStream(key, envelope.IV, offset)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.StreamReader{S: stream, R: data}, nil
+}
+
+// setEncryptionHeaders сообщает клиенту, как зашифрована версия объекта.
+// Для SSE-C возвращается MD5 ключа из запроса: сам ключ и его MD5 не хранятся.
+func setEncryptionHeaders(w http.ResponseWriter, r *http.Request, envelope sse.Envelope) {
+	if envelope.CustomerKey() {
+		w.Header().Set(customerKeyHeaderPrefix+"algorithm", envelope.Algorithm)
+		w.Header().Set(customerKeyHeaderPrefix+"key-MD5", r.Header.Get(customerKeyHeaderPrefix+"key-MD5"))
+	} else if envelope.Encrypted() {
+		w.Header().Set("x-amz-server-side-encryption", envelope.Algorithm)
+		if envelope.KMSKeyID != "" {
+			w.Header().Set("x-amz-server-side-encryption-aws-kms-key-id", envelope.KMSKeyID)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package object
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"triple-s/pkg/s3error"
+)
+
+const (
+	// lifecycleDirName — каталог журнала удалений по правилам жизненного цикла
+	lifecycleDirName = serviceDirPrefix + "lifecycle"
+	historyFileName  = "history.csv"
+
+	actionExpiration     = "Expiration"
+	actionNoncurrent     = "NoncurrentVersionExpiration"
+	actionExpiredMarker  = "ExpiredObjectDeleteMarker"
+	actionAbortMultipart = "AbortIncompleteMultipartUpload"
+)
+
+// lifecycleEvent — запись журнала о действии, выполненном по правилу
+type lifecycleEvent struct {
+	Time      time.Time
+	Bucket    string
+	Key       string
+	VersionID string
+	RuleID    string
+	Action    string
+}
+
+// StartLifecycleWorker запускает фоновое применение правил жизненного цикла ко всем ведрам
+// сразу и затем каждые interval
+func StartLifecycleWorker(dataDir string, interval time.Duration) {
+	go func() {
+		for {
+			if err := ApplyLifecycle(dataDir, time.Now()); err != nil {
+				log.Printf("lifecycle: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// ApplyLifecycle выполняет один проход правил жизненного цикла по всем ведрам.
+// Ошибка одного ведра не останавливает обработку остальных.
+func ApplyLifecycle(dataDir string, now time.Time) error {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return fmt.Errorf("unable to read data directory: %v", err)
+	}
+
+	var events []lifecycleEvent
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), "_") {
+			continue
+		}
+		config, found, err := loadLifecycle(dataDir, entry.Name())
+		if err != nil {
+			log.Printf("lifecycle: bucket %s: %v", entry.Name(), err)
+			continue
+		} else if !found {
+			continue
+		}
+		rules, err := compileLifecycle(config)
+		if err != nil {
+			log.Printf("lifecycle: bucket %s: %v", entry.Name(), err)
+			continue
+		}
+
+		bucketEvents, err := expireObjects(dataDir, entry.Name(), rules, now)
+		events = append(events, bucketEvents...)
+		if err != nil {
+			log.Printf("lifecycle: bucket %s: %v", entry.Name(), err)
+		}
+		uploadEvents, err := abortExpiredUploads(dataDir, entry.Name(), rules, now)
+		events = append(events, uploadEvents...)
+		if err != nil {
+			log.Printf("lifecycle: bucket %s: %v", entry.Name(), err)
+		}
+	}
+
+	return recordLifecycleEvents(dataDir, events)
+}
+
+// expireObjects удаляет версии объектов ведра, срок жизни которых истек
+func expireObjects(bucketDir, bucketName string, rules []lifecycleRule, now time.Time) ([]lifecycleEvent, error) {
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+
+	idx, err := loadBucketIndex(bucketDir, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []lifecycleEvent
+	record := func(key, versionID, ruleID, action string) {
+		events = append(events, lifecycleEvent{now, bucketName, key, versionID, ruleID, action})
+	}
+
+	// 1. Предыдущие версии: срок отсчитывается с момента, когда версия перестала быть текущей,
+	// то есть с создания следующей за ней версии
+	var noncurrent []objectRecord
+	newer := make(map[string]int)
+	for i := len(idx.versions) - 1; i >= 0; i-- {
+		version := idx.versions[i]
+		if version.DeleteMarker {
+			continue
+		}
+		becameNoncurrent, ok := idx.successorTime(i)
+		if !ok {
+			continue
+		}
+		// Версии под удержанием Object Lock не удаляются, но учитываются как более новые
+		locked := version.Lock.checkRemoval(now, false) != nil
+		for _, rule := range rules {
+			if locked || rule.NoncurrentDays == 0 || !rule.matches(version.Key, version.Size, version.Tags) {
+				continue
+			}
+			if newer[version.Key] >= rule.NewerNoncurrent && !now.Before(lifecycleDeadline(becameNoncurrent, rule.NoncurrentDays)) {
+				noncurrent = append(noncurrent, version)
+				record(version.Key, versionOf(version), rule.ID, actionNoncurrent)
+				break
+			}
+		}
+		newer[version.Key]++
+	}
+	for _, version := range noncurrent {
+		if _, err := idx.deleteVersion(version.Key, versionOf(version), false); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Текущие версии: в версионируемом ведре вместо удаления создается маркер удаления
+	var expired []objectRecord
+	for _, current := range idx.current {
+		created, err := time.Parse(time.RFC3339, current.LastModified)
+		if err != nil {
+			continue
+		}
+		for _, rule := range rules {
+			if rule.matches(current.Key, current.Size, current.Tags) && rule.expired(created, now) {
+				expired = append(expired, current)
+				record(current.Key, current.VersionID, rule.ID, actionExpiration)
+				break
+			}
+		}
+	}
+	for _, current := range expired {
+		if _, err := idx.deleteCurrent(current.Key); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. Маркеры удаления, которые остались единственной версией ключа
+	var markers []objectRecord
+	for _, version := range idx.versions {
+		if !version.DeleteMarker || idx.findCurrent(version.Key) >= 0 || idx.countVersions(version.Key) != 1 {
+			continue
+		}
+		for _, rule := range rules {
+			if rule.ExpiredMarker && strings.HasPrefix(version.Key, rule.Prefix) {
+				markers = append(markers, version)
+				record(version.Key, versionOf(version), rule.ID, actionExpiredMarker)
+				break
+			}
+		}
+	}
+	for _, marker := range markers {
+		if _, err := idx.deleteVersion(marker.Key, versionOf(marker), false); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(events) == 0 {
+		return nil, nil
+	}
+	if err := idx.save(); err != nil {
+		return nil, fmt.Errorf("unable to update object metadata: %w", err)
+	}
+	return events, nil
+}
+
+// successorTime возвращает время создания версии, сменившей предыдущую версию с позицией i
+func (idx *bucketIndex) successorTime(i int) (time.Time, bool) {
+	key := idx.versions[i].Key
+	successor, found := objectRecord{}, false
+	for _, version := range idx.versions[i+1:] {
+		if version.Key == key {
+			successor, found = version, true
+			break
+		}
+	}
+	if !found {
+		if j := idx.findCurrent(key); j >= 0 {
+			successor, found = idx.current[j], true
+		}
+	}
+	if !found {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, successor.LastModified)
+	return t, err == nil
+}
+
+// countVersions возвращает число предыдущих версий и маркеров удаления ключа
+func (idx *bucketIndex) countVersions(objectKey string) int {
+	count := 0
+	for _, version := range idx.versions {
+		if version.Key == objectKey {
+			count++
+		}
+	}
+	return count
+}
+
+// abortExpiredUploads отменяет незавершенные составные загрузки ведра старше срока из правил
+func abortExpiredUploads(bucketDir, bucketName string, rules []lifecycleRule, now time.Time) ([]lifecycleEvent, error) {
+	uploads, err := listMultipartUploads(bucketDir, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []lifecycleEvent
+	for _, upload := range uploads {
+		initiated, err := time.Parse(time.RFC3339, upload.Initiated)
+		if err != nil {
+			continue
+		}
+		for _, rule := range rules {
+			if rule.AbortDays == 0 || !strings.HasPrefix(upload.Key, rule.Prefix) || now.Before(lifecycleDeadline(initiated, rule.AbortDays)) {
+				continue
+			}
+			if err := removeMultipartUpload(bucketDir, upload.UploadID); errors.Is(err, s3error.ErrNoSuchUpload) {
+				// Загрузку успели завершить или отменить
+				break
+			} else if err != nil {
+				return events, err
+			}
+			events = append(events, lifecycleEvent{now, bucketName, upload.Key, upload.UploadID, rule.ID, actionAbortMultipart})
+			break
+		}
+	}
+	return events, nil
+}
+
+// recordLifecycleEvents дописывает выполненные действия в журнал _lifecycle/history.csv и в лог сервера
+func recordLifecycleEvents(dataDir string, events []lifecycleEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for _, event := range events {
+		target := event.Bucket + "/" + event.Key
+		if event.VersionID != "" {
+			target += " (" + event.VersionID + ")"
+		}
+		log.Printf("lifecycle: %s %s by rule %q", event.Action, target, event.RuleID)
+	}
+
+	historyDir := filepath.Join(dataDir, lifecycleDirName)
+	if err := os.MkdirAll(historyDir, 0o755); err != nil {
+		return fmt.Errorf("unable to create lifecycle directory: %v", err)
+	}
+	file, err := os.OpenFile(filepath.Join(historyDir, historyFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open lifecycle history: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	for _, event := range events {
+		writer.Write([]string{event.Time.UTC().Format(time.RFC3339), event.Bucket, event.Key, event.VersionID, event.RuleID, event.Action})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("unable to write lifecycle history: %v", err)
+	}
+	return nil
+}
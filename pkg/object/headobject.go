@@ -0,0 +1,44 @@
+package object
+
+import (
+	"net/http"
+
+	"triple-s/pkg/s3error"
+)
+
+// HeadObjectHandler возвращает метаданные объекта без тела ответа
+func HeadObjectHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	if bucketName == "" || objectKey == "" {
+		s3error.WriteError(w, r, s3error.ErrInvalidURI.WithMessage("missing bucket name or object key"))
+		return
+	}
+
+	// 1. Поиск запрошенной версии объекта и ее метаданных (файлы objects.csv и versions.csv
+	// читаются под одной блокировкой, чтобы не попасть между ними при параллельной записи)
+	metadataMu.RLock()
+	record, _, err := lookupVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"))
+	metadataMu.RUnlock()
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// 2. Проверка ключа клиента (SSE-C) и условных заголовков
+	if _, err := readKey(r.Header, customerKeyHeaderPrefix, record.Encryption); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if notModified, err := requestConditions(r.Header).check(record); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	} else if notModified {
+		writeNotModified(w, record)
+		return
+	}
+
+	// 3. Возвращаем только заголовки
+	setObjectHeaders(w, record)
+	setEncryptionHeaders(w, r, record.Encryption)
+	w.WriteHeader(http.StatusOK)
+}
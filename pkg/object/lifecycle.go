@@ -0,0 +1,365 @@
+package object
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"triple-s/pkg/s3error"
+)
+
+const (
+	lifecycleFileName = "lifecycle.xml"
+	maxLifecycleRules = 1000
+	// maxLifecycleBodySize ограничивает размер тела PutBucketLifecycleConfiguration
+	maxLifecycleBodySize = 1 << 20
+)
+
+// LifecycleConfiguration — тело PutBucketLifecycleConfiguration и ответ GetBucketLifecycleConfiguration
+type LifecycleConfiguration struct {
+	XMLName xml.Name
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+// LifecycleRule — одно правило жизненного цикла
+type LifecycleRule struct {
+	ID     string           `xml:"ID,omitempty"`
+	Filter *LifecycleFilter `xml:"Filter"`
+	// Prefix — устаревшая форма фильтра на уровне правила
+	Prefix                         *string                         `xml:"Prefix"`
+	Status                         string                          `xml:"Status"`
+	Expiration                     *LifecycleExpiration            `xml:"Expiration"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload"`
+}
+
+// LifecycleFilter выбирает объекты, к которым применяется правило.
+// Задается одно условие либо их сочетание в And.
+type LifecycleFilter struct {
+	Prefix                *string       `xml:"Prefix"`
+	Tag                   *LifecycleTag `xml:"Tag"`
+	ObjectSizeGreaterThan *int64        `xml:"ObjectSizeGreaterThan"`
+	ObjectSizeLessThan    *int64        `xml:"ObjectSizeLessThan"`
+	And                   *LifecycleAnd `xml:"And"`
+}
+
+// LifecycleAnd — сочетание условий фильтра
+type LifecycleAnd struct {
+	Prefix                string         `xml:"Prefix,omitempty"`
+	Tags                  []LifecycleTag `xml:"Tag"`
+	ObjectSizeGreaterThan *int64         `xml:"ObjectSizeGreaterThan"`
+	ObjectSizeLessThan    *int64         `xml:"ObjectSizeLessThan"`
+}
+
+// LifecycleTag — тег объекта в фильтре
+type LifecycleTag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// LifecycleExpiration — срок жизни текущей версии объекта
+type LifecycleExpiration struct {
+	Days                      int    `xml:"Days,omitempty"`
+	Date                      string `xml:"Date,omitempty"`
+	ExpiredObjectDeleteMarker bool   `xml:"ExpiredObjectDeleteMarker,omitempty"`
+}
+
+// NoncurrentVersionExpiration — срок хранения предыдущих версий
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays          int `xml:"NoncurrentDays"`
+	NewerNoncurrentVersions int `xml:"NewerNoncurrentVersions,omitempty"`
+}
+
+// AbortIncompleteMultipartUpload — срок, после которого незавершенная загрузка отменяется
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+// lifecycleRule — проверенное правило в удобном для применения виде
+type lifecycleRule struct {
+	ID              string
+	Prefix          string
+	Tags            map[string]string
+	SizeGreaterThan *int64
+	SizeLessThan    *int64
+	ExpirationDays  int
+	ExpirationDate  time.Time
+	ExpiredMarker   bool
+	NoncurrentDays  int
+	NewerNoncurrent int
+	AbortDays       int
+}
+
+// PutBucketLifecycleHandler сохраняет правила жизненного цикла ведра (PUT /{bucket}?lifecycle)
+func PutBucketLifecycleHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	// 1. Разбор и проверка правил
+	var config LifecycleConfiguration
+	if err := readXMLBody(r, maxLifecycleBodySize, &config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if _, err := compileLifecycle(config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// 2. Сохранение конфигурации рядом с метаданными ведра
+	config.XMLName = xml.Name{Local: "LifecycleConfiguration"}
+	data, err := xml.Marshal(config)
+	if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to encode lifecycle configuration: %v", err))
+		return
+	}
+	if err := writeBucketConfig(bucketDir, bucketName, lifecycleFileName, data); err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to save lifecycle configuration: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketLifecycleHandler возвращает правила жизненного цикла ведра (GET /{bucket}?lifecycle)
+func GetBucketLifecycleHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	config, found, err := loadLifecycle(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !found {
+		s3error.WriteError(w, r, s3error.ErrNoSuchLifecycle)
+		return
+	}
+	config.XMLName = s3error.XMLName("LifecycleConfiguration")
+	s3error.WriteXML(w, r, http.StatusOK, config)
+}
+
+// DeleteBucketLifecycleHandler удаляет правила жизненного цикла ведра (DELETE /{bucket}?lifecycle)
+func DeleteBucketLifecycleHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	if err := os.Remove(filepath.Join(bucketPath, lifecycleFileName)); err != nil && !os.IsNotExist(err) {
+		s3error.WriteError(w, r, fmt.Errorf("unable to delete lifecycle configuration: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadLifecycle читает сохраненную конфигурацию жизненного цикла ведра
+func loadLifecycle(bucketDir, bucketName string) (LifecycleConfiguration, bool, error) {
+	data, err := os.ReadFile(filepath.Join(bucketDir, bucketName, lifecycleFileName))
+	if os.IsNotExist(err) {
+		return LifecycleConfiguration{}, false, nil
+	} else if err != nil {
+		return LifecycleConfiguration{}, false, fmt.Errorf("unable to read lifecycle configuration: %v", err)
+	}
+	var config LifecycleConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return LifecycleConfiguration{}, false, fmt.Errorf("malformed lifecycle configuration: %v", err)
+	}
+	return config, true, nil
+}
+
+// compileLifecycle проверяет конфигурацию и возвращает включенные правила
+func compileLifecycle(config LifecycleConfiguration) ([]lifecycleRule, error) {
+	if len(config.Rules) == 0 || len(config.Rules) > maxLifecycleRules {
+		return nil, s3error.ErrMalformedXML.WithMessage("a lifecycle configuration must contain between 1 and 1000 rules")
+	}
+
+	var rules []lifecycleRule
+	ids := make(map[string]bool, len(config.Rules))
+	for _, rule := range config.Rules {
+		compiled, err := compileLifecycleRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		if rule.ID != "" {
+			if ids[rule.ID] {
+				return nil, s3error.ErrInvalidArgument.WithMessage("rule ID must be unique. Found same ID for more than one rule")
+			}
+			ids[rule.ID] = true
+		}
+		if rule.Status == "Enabled" {
+			rules = append(rules, compiled)
+		}
+	}
+	return rules, nil
+}
+
+// compileLifecycleRule проверяет одно правило
+func compileLifecycleRule(rule LifecycleRule) (lifecycleRule, error) {
+	compiled := lifecycleRule{ID: rule.ID}
+	invalid := func(message string) (lifecycleRule, error) {
+		return lifecycleRule{}, s3error.ErrInvalidArgument.WithMessage(message)
+	}
+
+	if len(rule.ID) > 255 {
+		return invalid("ID length should not exceed allowed limit of 255")
+	}
+	if rule.Status != "Enabled" && rule.Status != "Disabled" {
+		return lifecycleRule{}, s3error.ErrMalformedXML.WithMessage("rule status must be Enabled or Disabled")
+	}
+
+	// Фильтр: устаревший Prefix правила или элемент Filter, но не оба сразу
+	if rule.Prefix != nil && rule.Filter != nil {
+		return lifecycleRule{}, s3error.ErrMalformedXML.WithMessage("a rule cannot have both Prefix and Filter")
+	}
+	if rule.Prefix != nil {
+		compiled.Prefix = *rule.Prefix
+	}
+	if filter := rule.Filter; filter != nil {
+		if filter.And != nil {
+			if filter.Prefix != nil || filter.Tag != nil || filter.ObjectSizeGreaterThan != nil || filter.ObjectSizeLessThan != nil {
+				return lifecycleRule{}, s3error.ErrMalformedXML.WithMessage("And cannot be combined with other filter elements")
+			}
+			compiled.Prefix = filter.And.Prefix
+			compiled.SizeGreaterThan, compiled.SizeLessThan = filter.And.ObjectSizeGreaterThan, filter.And.ObjectSizeLessThan
+			for _, tag := range filter.And.Tags {
+				if compiled.Tags == nil {
+					compiled.Tags = make(map[string]string)
+				}
+				if _, duplicate := compiled.Tags[tag.Key]; duplicate {
+					return invalid("duplicate tag key in filter: " + tag.Key)
+				}
+				compiled.Tags[tag.Key] = tag.Value
+			}
+		} else {
+			conditions := 0
+			if filter.Prefix != nil {
+				compiled.Prefix = *filter.Prefix
+				conditions++
+			}
+			if filter.Tag != nil {
+				compiled.Tags = map[string]string{filter.Tag.Key: filter.Tag.Value}
+				conditions++
+			}
+			if filter.ObjectSizeGreaterThan != nil || filter.ObjectSizeLessThan != nil {
+				compiled.SizeGreaterThan, compiled.SizeLessThan = filter.ObjectSizeGreaterThan, filter.ObjectSizeLessThan
+				conditions++
+			}
+			if conditions > 1 {
+				return lifecycleRule{}, s3error.ErrMalformedXML.WithMessage("use And to combine several filter conditions")
+			}
+		}
+	}
+	if gt, lt := compiled.SizeGreaterThan, compiled.SizeLessThan; (gt != nil && *gt < 0) || (lt != nil && *lt <= 0) || (gt != nil && lt != nil && *gt >= *lt) {
+		return invalid("ObjectSizeGreaterThan must be less than ObjectSizeLessThan and both must be non-negative")
+	}
+
+	// Действия правила
+	actions := 0
+	if expiration := rule.Expiration; expiration != nil {
+		set := 0
+		if expiration.Days != 0 {
+			if expiration.Days < 0 {
+				return invalid("'Days' for Expiration action must be a positive integer")
+			}
+			compiled.ExpirationDays = expiration.Days
+			set++
+		}
+		if expiration.Date != "" {
+			date, err := time.Parse(time.RFC3339, expiration.Date)
+			if err != nil || !date.UTC().Equal(date.UTC().Truncate(24*time.Hour)) {
+				return invalid("'Date' must be at midnight GMT")
+			}
+			compiled.ExpirationDate = date.UTC()
+			set++
+		}
+		if expiration.ExpiredObjectDeleteMarker {
+			if compiled.filtersByTagOrSize() {
+				return invalid("ExpiredObjectDeleteMarker cannot be specified with tag or size filters")
+			}
+			compiled.ExpiredMarker = true
+			set++
+		}
+		if set != 1 {
+			return lifecycleRule{}, s3error.ErrMalformedXML.WithMessage("Expiration must specify exactly one of Days, Date or ExpiredObjectDeleteMarker")
+		}
+		actions++
+	}
+	if noncurrent := rule.NoncurrentVersionExpiration; noncurrent != nil {
+		if noncurrent.NoncurrentDays <= 0 || noncurrent.NewerNoncurrentVersions < 0 {
+			return invalid("'NoncurrentDays' for NoncurrentVersionExpiration action must be a positive integer")
+		}
+		compiled.NoncurrentDays, compiled.NewerNoncurrent = noncurrent.NoncurrentDays, noncurrent.NewerNoncurrentVersions
+		actions++
+	}
+	if abort := rule.AbortIncompleteMultipartUpload; abort != nil {
+		if abort.DaysAfterInitiation <= 0 {
+			return invalid("'DaysAfterInitiation' for AbortIncompleteMultipartUpload action must be a positive integer")
+		}
+		if compiled.filtersByTagOrSize() {
+			return invalid("AbortIncompleteMultipartUpload cannot be specified with tag or size filters")
+		}
+		compiled.AbortDays = abort.DaysAfterInitiation
+		actions++
+	}
+	if actions == 0 {
+		return invalid("at least one action needs to be specified in a rule")
+	}
+	return compiled, nil
+}
+
+// filtersByTagOrSize сообщает, отбирает ли правило объекты по тегам или размеру.
+// Такие фильтры неприменимы к маркерам удаления и незавершенным загрузкам.
+func (rule lifecycleRule) filtersByTagOrSize() bool {
+	return len(rule.Tags) > 0 || rule.SizeGreaterThan != nil || rule.SizeLessThan != nil
+}
+
+// matches проверяет, подпадает ли объект под фильтр правила
+func (rule lifecycleRule) matches(key string, size int64, tags map[string]string) bool {
+	if !strings.HasPrefix(key, rule.Prefix) {
+		return false
+	}
+	if rule.SizeGreaterThan != nil && size <= *rule.SizeGreaterThan {
+		return false
+	}
+	if rule.SizeLessThan != nil && size >= *rule.SizeLessThan {
+		return false
+	}
+	for name, value := range rule.Tags {
+		if actual, ok := tags[name]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// expired проверяет, истек ли срок жизни текущей версии, созданной в момент created
+func (rule lifecycleRule) expired(created, now time.Time) bool {
+	if rule.ExpirationDays > 0 {
+		return !now.Before(lifecycleDeadline(created, rule.ExpirationDays))
+	}
+	if !rule.ExpirationDate.IsZero() {
+		return !now.Before(rule.ExpirationDate)
+	}
+	return false
+}
+
+// lifecycleDeadline возвращает момент, когда истекают days дней от t.
+// Как и в S3, срок округляется вверх до ближайшей полуночи UTC.
+func lifecycleDeadline(t time.Time, days int) time.Time {
+	deadline := t.UTC().AddDate(0, 0, days)
+	midnight := deadline.Truncate(24 * time.Hour)
+	if midnight.Before(deadline) {
+		midnight = midnight.AddDate(0, 0, 1)
+	}
+	return midnight
+}
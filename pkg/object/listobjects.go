@@ -0,0 +1,198 @@
+package object
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"triple-s/pkg/s3error"
+)
+
+const defaultMaxKeys = 1000
+
+// commonPrefixToken отмечает токен продолжения, указывающий на общий префикс, а не на ключ.
+// Байт 0xff не встречается в UTF-8, поэтому с ключом такой токен не спутать.
+const commonPrefixToken = "\xffprefix:"
+
+// ListBucketResult представляет XML-ответ ListObjectsV2
+type ListBucketResult struct {
+	XMLName               xml.Name         `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	EncodingType          string           `xml:"EncodingType,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int              `xml:"KeyCount"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	Contents              []ListObjectItem `xml:"Contents"`
+	CommonPrefixes        []CommonPrefix   `xml:"CommonPrefixes"`
+}
+
+// CommonPrefix описывает группу ключей, свернутую по разделителю
+type CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// ListObjectItem описывает объект в ответе ListObjectsV2
+type ListObjectItem struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// ListObjectsHandler обрабатывает запрос ListObjectsV2 для ведра
+func ListObjectsHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if bucketName == "" {
+		s3error.WriteError(w, r, s3error.ErrInvalidURI.WithMessage("missing bucket name"))
+		return
+	}
+
+	// 1. Проверка существования ведра
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	// 2. Разбор параметров запроса
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	startAfter := query.Get("start-after")
+	continuationToken := query.Get("continuation-token")
+	encodingType := query.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("invalid encoding method specified in request"))
+		return
+	}
+
+	maxKeys, err := queryInt(query.Get("max-keys"), defaultMaxKeys)
+	if err != nil {
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("max-keys must be a non-negative integer"))
+		return
+	}
+
+	// Токен продолжения имеет приоритет над start-after.
+	// Если страница закончилась общим префиксом, токен хранит его, и все ключи
+	// под этим префиксом пропускаются при продолжении.
+	marker, skipPrefix := startAfter, ""
+	if continuationToken != "" {
+		decoded, err := base64.URLEncoding.DecodeString(continuationToken)
+		if err != nil {
+			s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("invalid continuation token"))
+			return
+		}
+		marker = string(decoded)
+		if commonPrefix, ok := strings.CutPrefix(marker, commonPrefixToken); ok {
+			marker, skipPrefix = commonPrefix, commonPrefix
+		}
+	}
+
+	// 3. Чтение метаданных объектов
+	records, err := loadObjectRecords(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+
+	// 4. Отбор объектов по префиксу и маркеру; ключи с разделителем после префикса
+	// сворачиваются в CommonPrefixes, которые тоже учитываются в max-keys
+	result := ListBucketResult{
+		Name:              bucketName,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		EncodingType:      encodingType,
+		StartAfter:        startAfter,
+		ContinuationToken: continuationToken,
+		MaxKeys:           maxKeys,
+	}
+	lastEntry := ""
+	for _, record := range records {
+		if !strings.HasPrefix(record.Key, prefix) || record.Key <= marker {
+			continue
+		}
+		if skipPrefix != "" && strings.HasPrefix(record.Key, skipPrefix) {
+			continue
+		}
+
+		commonPrefix := ""
+		if delimiter != "" {
+			if i := strings.Index(record.Key[len(prefix):], delimiter); i >= 0 {
+				commonPrefix = record.Key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if commonPrefix != "" && lastEntry == commonPrefixToken+commonPrefix {
+			continue
+		}
+
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		result.KeyCount++
+		if commonPrefix != "" {
+			result.CommonPrefixes = append(result.CommonPrefixes, CommonPrefix{Prefix: commonPrefix})
+			lastEntry = commonPrefixToken + commonPrefix
+			continue
+		}
+		result.Contents = append(result.Contents, ListObjectItem{
+			Key:          record.Key,
+			LastModified: record.LastModified,
+			ETag:         record.ETag,
+			Size:         record.Size,
+			StorageClass: "STANDARD",
+		})
+		lastEntry = record.Key
+	}
+	if result.IsTruncated && lastEntry != "" {
+		result.NextContinuationToken = base64.URLEncoding.EncodeToString([]byte(lastEntry))
+	}
+	if encodingType == "url" {
+		result.encodeKeys()
+	}
+
+	// 5. Отправка ответа в формате XML
+	s3error.WriteXML(w, r, http.StatusOK, result)
+}
+
+// encodeKeys кодирует ключи и префиксы ответа для encoding-type=url,
+// чтобы в XML можно было передать ключи с управляющими символами
+func (result *ListBucketResult) encodeKeys() {
+	result.Prefix = url.PathEscape(result.Prefix)
+	result.Delimiter = url.PathEscape(result.Delimiter)
+	result.StartAfter = url.PathEscape(result.StartAfter)
+	for i := range result.Contents {
+		result.Contents[i].Key = url.PathEscape(result.Contents[i].Key)
+	}
+	for i := range result.CommonPrefixes {
+		result.CommonPrefixes[i].Prefix = url.PathEscape(result.CommonPrefixes[i].Prefix)
+	}
+}
+
+// queryInt разбирает неотрицательный целочисленный параметр запроса с ограничением сверху
+func queryInt(value string, limit int) (int, error) {
+	if value == "" {
+		return limit, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid integer %q", value)
+	}
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return n, nil
+}
@@ -0,0 +1,198 @@
+package object
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"triple-s/pkg/acl"
+	"triple-s/pkg/s3error"
+	"triple-s/pkg/sse"
+)
+
+// objectRecord представляет одну строку файла objects.csv
+type objectRecord struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified string
+	ETag         string
+	// Metadata — пользовательские x-amz-meta-* и сохраненные стандартные заголовки
+	Metadata map[string]string
+	// VersionID — идентификатор версии; пустой у объектов, записанных до включения версионирования
+	VersionID string
+	// DeleteMarker отмечает маркер удаления (только в versions.csv)
+	DeleteMarker bool
+	// Tags — теги объекта
+	Tags map[string]string
+	// ACL — владелец версии объекта и выданные разрешения
+	ACL acl.ACL
+	// Encryption — шифрование данных версии; пустое у открытых данных
+	Encryption sse.Envelope
+	// Lock — удержание версии (Object Lock)
+	Lock objectLock
+}
+
+// metadataMu защищает чтение-изменение-запись файлов objects.csv и versions.csv
+var metadataMu sync.RWMutex
+
+// parseObjectRecord разбирает строку CSV в objectRecord
+func parseObjectRecord(record []string) (objectRecord, error) {
+	if len(record) < 4 {
+		return objectRecord{}, fmt.Errorf("malformed object metadata record: %v", record)
+	}
+	size, err := strconv.ParseInt(record[1], 10, 64)
+	if err != nil {
+		return objectRecord{}, fmt.Errorf("malformed object size for %q: %v", record[0], err)
+	}
+	object := objectRecord{
+		Key:          record[0],
+		Size:         size,
+		ContentType:  record[2],
+		LastModified: record[3],
+	}
+	if len(record) > 4 {
+		object.ETag = record[4]
+	}
+	if len(record) > 5 {
+		object.Metadata, err = decodeMetadata(record[5])
+		if err != nil {
+			return objectRecord{}, fmt.Errorf("malformed metadata for %q: %v", record[0], err)
+		}
+	}
+	if len(record) > 6 {
+		object.VersionID = record[6]
+	}
+	if len(record) > 7 {
+		object.DeleteMarker = record[7] == "true"
+	}
+	if len(record) > 8 {
+		object.Tags, err = decodeMetadata(record[8])
+		if err != nil {
+			return objectRecord{}, fmt.Errorf("malformed tags for %q: %v", record[0], err)
+		}
+	}
+	if len(record) > 9 {
+		object.ACL, err = acl.Decode(record[9])
+		if err != nil {
+			return objectRecord{}, fmt.Errorf("malformed ACL for %q: %v", record[0], err)
+		}
+	}
+	if len(record) > 10 {
+		object.Encryption, err = sse.DecodeEnvelope(record[10])
+		if err != nil {
+			return objectRecord{}, fmt.Errorf("malformed encryption for %q: %v", record[0], err)
+		}
+	}
+	if len(record) > 11 {
+		object.Lock, err = decodeObjectLock(record[11])
+		if err != nil {
+			return objectRecord{}, fmt.Errorf("malformed object lock for %q: %v", record[0], err)
+		}
+	}
+	return object, nil
+}
+
+// formatObjectRecord преобразует objectRecord в строку CSV
+func formatObjectRecord(object objectRecord) []string {
+	return []string{
+		object.Key,
+		strconv.FormatInt(object.Size, 10),
+		object.ContentType,
+		object.LastModified,
+		object.ETag,
+		encodeMetadata(object.Metadata),
+		object.VersionID,
+		strconv.FormatBool(object.DeleteMarker),
+		encodeMetadata(object.Tags),
+		object.ACL.Encode(),
+		object.Encryption.Encode(),
+		object.Lock.encode(),
+	}
+}
+
+// loadObjectRecords читает все записи об объектах ведра.
+// Отсутствующий файл метаданных означает пустое ведро.
+func loadObjectRecords(bucketDir, bucketName string) ([]objectRecord, error) {
+	file, err := os.Open(filepath.Join(bucketDir, bucketName, "objects.csv"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to open objects metadata file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read objects metadata: %v", err)
+	}
+
+	objects := make([]objectRecord, 0, len(records))
+	for _, record := range records {
+		object, err := parseObjectRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// findObjectRecord ищет запись об объекте по ключу
+func findObjectRecord(bucketDir, bucketName, objectKey string) (objectRecord, bool, error) {
+	records, err := loadObjectRecords(bucketDir, bucketName)
+	if err != nil {
+		return objectRecord{}, false, err
+	}
+	for _, record := range records {
+		if record.Key == objectKey {
+			return record, true, nil
+		}
+	}
+	return objectRecord{}, false, nil
+}
+
+// lookupObject возвращает метаданные существующего объекта.
+// Для отсутствующего ведра или объекта возвращаются NoSuchBucket и NoSuchKey.
+func lookupObject(bucketDir, bucketName, objectKey string) (objectRecord, error) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		return objectRecord{}, s3error.ErrNoSuchBucket
+	}
+
+	record, found, err := findObjectRecord(bucketDir, bucketName, objectKey)
+	if err != nil {
+		return objectRecord{}, err
+	}
+	if !found {
+		return objectRecord{}, s3error.ErrNoSuchKey
+	}
+	return record, nil
+}
+
+// setObjectHeaders устанавливает заголовки ответа по метаданным объекта
+func setObjectHeaders(w http.ResponseWriter, record objectRecord) {
+	w.Header().Set("Content-Type", record.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(record.Size, 10))
+	if modified, err := time.Parse(time.RFC3339, record.LastModified); err == nil {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if record.ETag != "" {
+		w.Header().Set("ETag", record.ETag)
+	}
+	if record.VersionID != "" {
+		w.Header().Set("x-amz-version-id", record.VersionID)
+	}
+	if len(record.Tags) > 0 {
+		w.Header().Set("x-amz-tagging-count", strconv.Itoa(len(record.Tags)))
+	}
+	setObjectLockHeaders(w, record.Lock)
+	setMetadataHeaders(w, record.Metadata)
+}
@@ -0,0 +1,888 @@
+package object
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"triple-s/pkg/acl"
+	"triple-s/pkg/s3error"
+	"triple-s/pkg/sse"
+)
+
+const (
+	// multipartDirName — каталог для частей незавершенных загрузок
+	multipartDirName = serviceDirPrefix + "multipart"
+	maxPartNumber    = 10000
+	minPartSize      = 5 << 20
+	defaultMaxParts  = 1000
+	// maxCompleteBodySize ограничивает тело CompleteMultipartUpload: до 10000 частей с ETag
+	maxCompleteBodySize = 2 << 20
+)
+
+// multipartMu защищает uploads.csv, parts.csv и completingUploads
+var multipartMu sync.Mutex
+
+// completingUploads — загрузки, которые сейчас собирает CompleteMultipartUpload.
+// Для остальных запросов их уже нет: повторное завершение, отмена и загрузка части
+// получают NoSuchUpload, поэтому части не меняются и не удаляются во время сборки.
+var completingUploads = map[string]bool{}
+
+// multipartUpload описывает незавершенную составную загрузку (строка uploads.csv)
+type multipartUpload struct {
+	UploadID    string
+	Bucket      string
+	Key         string
+	Initiated   string
+	ContentType string
+	Metadata    map[string]string
+	Tags        map[string]string
+	ACL         acl.ACL
+	// Encryption — алгоритм и обернутый ключ данных загрузки; IV у каждой части свой
+	Encryption sse.Envelope
+	// Lock — удержание, которое получит собранный объект
+	Lock objectLock
+}
+
+// uploadPart описывает загруженную часть (строка parts.csv)
+type uploadPart struct {
+	PartNumber   int
+	ETag         string
+	Size         int64
+	LastModified string
+	// IV — начальный вектор, которым зашифрована часть; пустой у открытых данных
+	IV []byte
+}
+
+// InitiateMultipartUploadResult — ответ CreateMultipartUpload
+type InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// CompleteMultipartUpload — тело запроса CompleteMultipartUpload
+type CompleteMultipartUpload struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []CompletePart `xml:"Part"`
+}
+
+// CompletePart — часть, перечисленная в запросе CompleteMultipartUpload
+type CompletePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// CompleteMultipartUploadResult — ответ CompleteMultipartUpload
+type CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// ListPartsResult — ответ ListParts
+type ListPartsResult struct {
+	XMLName              xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket               string     `xml:"Bucket"`
+	Key                  string     `xml:"Key"`
+	UploadID             string     `xml:"UploadId"`
+	PartNumberMarker     int        `xml:"PartNumberMarker"`
+	NextPartNumberMarker int        `xml:"NextPartNumberMarker"`
+	MaxParts             int        `xml:"MaxParts"`
+	IsTruncated          bool       `xml:"IsTruncated"`
+	Parts                []PartItem `xml:"Part"`
+}
+
+// PartItem описывает часть в ответе ListParts
+type PartItem struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+// ListMultipartUploadsResult — ответ ListMultipartUploads
+type ListMultipartUploadsResult struct {
+	XMLName     xml.Name     `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+	Bucket      string       `xml:"Bucket"`
+	Prefix      string       `xml:"Prefix"`
+	MaxUploads  int          `xml:"MaxUploads"`
+	IsTruncated bool         `xml:"IsTruncated"`
+	Uploads     []UploadItem `xml:"Upload"`
+}
+
+// UploadItem описывает незавершенную загрузку в ответе ListMultipartUploads
+type UploadItem struct {
+	Key          string `xml:"Key"`
+	UploadID     string `xml:"UploadId"`
+	Initiated    string `xml:"Initiated"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// CreateMultipartUploadHandler начинает составную загрузку (POST /{bucket}/{key}?uploads)
+func CreateMultipartUploadHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	if bucketName == "" || objectKey == "" {
+		s3error.WriteError(w, r, s3error.ErrInvalidURI.WithMessage("missing bucket name or object key"))
+		return
+	}
+
+	// 1. Проверка существования ведра и ключа объекта
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+	if err := validateObjectKey(objectKey); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	metadata, err := extractMetadata(r.Header)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	tags, err := parseTaggingHeader(r.Header.Get("x-amz-tagging"))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	objectACL, err := acl.Canned(r.Header.Get("x-amz-acl"), requestOwner(r, bucketDir, bucketName))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	encryption, err := requestEncryption(r, bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if encryption.customerKey != nil {
+		s3error.WriteError(w, r, s3error.ErrNotImplemented.WithMessage("customer-provided encryption keys are not supported for multipart uploads"))
+		return
+	}
+	lock, err := requestObjectLock(r, bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// 2. Создание ключа данных и каталога для частей загрузки
+	envelope, _, err := newEnvelope(encryption)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	envelope.IV = nil
+	uploadID, err := newUploadID()
+	if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to generate upload id: %v", err))
+		return
+	}
+	if err := os.MkdirAll(uploadPath(bucketDir, uploadID), 0o755); err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to create upload directory: %v", err))
+		return
+	}
+
+	// 3. Запись информации о загрузке
+	upload := multipartUpload{
+		UploadID:    uploadID,
+		Bucket:      bucketName,
+		Key:         objectKey,
+		Initiated:   time.Now().Format(time.RFC3339),
+		ContentType: r.Header.Get("Content-Type"),
+		Metadata:    metadata,
+		Tags:        tags,
+		ACL:         objectACL,
+		Encryption:  envelope,
+		Lock:        lock,
+	}
+	if err := saveMultipartUpload(bucketDir, upload); err != nil {
+		os.RemoveAll(uploadPath(bucketDir, uploadID))
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	setEncryptionHeaders(w, r, envelope)
+	s3error.WriteXML(w, r, http.StatusOK, InitiateMultipartUploadResult{Bucket: bucketName, Key: objectKey, UploadID: uploadID})
+}
+
+// UploadPartHandler сохраняет одну часть загрузки (PUT /{bucket}/{key}?partNumber=N&uploadId=ID)
+func UploadPartHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	query := r.URL.Query()
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("part number must be an integer between 1 and 10000"))
+		return
+	}
+
+	// 1. Поиск загрузки
+	upload, found, err := findMultipartUpload(bucketDir, query.Get("uploadId"))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !found || upload.Bucket != bucketName || upload.Key != objectKey {
+		s3error.WriteError(w, r, s3error.ErrNoSuchUpload)
+		return
+	}
+
+	// 2. Потоковая запись части с подсчетом MD5; часть шифруется ключом загрузки со своим IV
+	expectedMD5, err := parseContentMD5(r.Header.Get("Content-MD5"))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	envelope, key, err := uploadEnvelope(upload)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	hash := md5.New()
+	tmpPath, size, err := writeObjectData(bucketDir, r.Body, envelope, key, hash)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if expectedMD5 != nil && !bytes.Equal(hash.Sum(nil), expectedMD5) {
+		os.Remove(tmpPath)
+		s3error.WriteError(w, r, s3error.ErrBadDigest)
+		return
+	}
+	etag, err := objectETag(envelope, hash.Sum(nil))
+	if err != nil {
+		os.Remove(tmpPath)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	partPath := filepath.Join(uploadPath(bucketDir, upload.UploadID), strconv.Itoa(partNumber))
+	if err := commitTempObject(tmpPath, partPath); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// 3. Обновление списка частей
+	part := uploadPart{
+		PartNumber:   partNumber,
+		ETag:         etag,
+		Size:         size,
+		LastModified: time.Now().Format(time.RFC3339),
+		IV:           envelope.IV,
+	}
+	if err := saveUploadPart(bucketDir, upload.UploadID, part); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	setEncryptionHeaders(w, r, envelope)
+	w.Header().Set("ETag", part.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+// CompleteMultipartUploadHandler собирает части в итоговый объект (POST /{bucket}/{key}?uploadId=ID)
+func CompleteMultipartUploadHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	// 1. Поиск загрузки и отметка о начале сборки
+	upload, found, err := claimMultipartUpload(bucketDir, bucketName, objectKey, r.URL.Query().Get("uploadId"))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !found {
+		s3error.WriteError(w, r, s3error.ErrNoSuchUpload)
+		return
+	}
+	defer releaseMultipartUpload(upload.UploadID)
+
+	// 2. Разбор списка частей из тела запроса
+	var request CompleteMultipartUpload
+	if err := readXMLBody(r, maxCompleteBodySize, &request); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if len(request.Parts) == 0 || len(request.Parts) > maxPartNumber {
+		s3error.WriteError(w, r, s3error.ErrMalformedXML)
+		return
+	}
+
+	parts, err := loadUploadParts(bucketDir, upload.UploadID)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	stored := make(map[int]uploadPart, len(parts))
+	for _, part := range parts {
+		stored[part.PartNumber] = part
+	}
+
+	// 3. Проверка порядка, наличия, ETag и размера частей
+	selected := make([]uploadPart, 0, len(request.Parts))
+	for i, requested := range request.Parts {
+		if i > 0 && requested.PartNumber <= request.Parts[i-1].PartNumber {
+			s3error.WriteError(w, r, s3error.ErrInvalidPartOrder)
+			return
+		}
+		part, ok := stored[requested.PartNumber]
+		if !ok || strings.Trim(requested.ETag, `"`) != strings.Trim(part.ETag, `"`) {
+			s3error.WriteError(w, r, s3error.ErrInvalidPart)
+			return
+		}
+		if i < len(request.Parts)-1 && part.Size < minPartSize {
+			s3error.WriteError(w, r, s3error.ErrEntityTooSmall)
+			return
+		}
+		selected = append(selected, part)
+	}
+
+	// 4. Сборка объекта во временном файле; зашифрованные части перешифровываются
+	// ключом загрузки с новым IV объекта
+	envelope, key, err := uploadEnvelope(upload)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	tmpPath, etag, size, err := assembleParts(bucketDir, upload, selected, envelope, key)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// 5. Сохранение объекта как новой версии и удаление частей
+	record, err := putObject(bucketDir, bucketName, tmpPath, objectRecord{
+		Key:          objectKey,
+		Size:         size,
+		ContentType:  defaultContentType(objectKey, upload.ContentType),
+		LastModified: time.Now().Format(time.RFC3339),
+		ETag:         etag,
+		Metadata:     upload.Metadata,
+		Tags:         upload.Tags,
+		ACL:          upload.ACL,
+		Encryption:   envelope,
+		Lock:         upload.Lock,
+	}, false)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if err := finishMultipartUpload(bucketDir, upload.UploadID); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	if record.VersionID != "" {
+		w.Header().Set("x-amz-version-id", record.VersionID)
+	}
+	setEncryptionHeaders(w, r, envelope)
+
+	s3error.WriteXML(w, r, http.StatusOK, CompleteMultipartUploadResult{
+		Location: "/" + bucketName + "/" + objectKey,
+		Bucket:   bucketName,
+		Key:      objectKey,
+		ETag:     etag,
+	})
+}
+
+// AbortMultipartUploadHandler отменяет загрузку и удаляет ее части (DELETE /{bucket}/{key}?uploadId=ID)
+func AbortMultipartUploadHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	upload, found, err := findMultipartUpload(bucketDir, r.URL.Query().Get("uploadId"))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !found || upload.Bucket != bucketName || upload.Key != objectKey {
+		s3error.WriteError(w, r, s3error.ErrNoSuchUpload)
+		return
+	}
+
+	if err := removeMultipartUpload(bucketDir, upload.UploadID); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPartsHandler возвращает загруженные части (GET /{bucket}/{key}?uploadId=ID)
+func ListPartsHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	query := r.URL.Query()
+	upload, found, err := findMultipartUpload(bucketDir, query.Get("uploadId"))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !found || upload.Bucket != bucketName || upload.Key != objectKey {
+		s3error.WriteError(w, r, s3error.ErrNoSuchUpload)
+		return
+	}
+
+	maxParts, err := queryInt(query.Get("max-parts"), defaultMaxParts)
+	if err != nil {
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("max-parts must be a non-negative integer"))
+		return
+	}
+	marker, err := queryInt(query.Get("part-number-marker"), 0)
+	if err != nil {
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("part-number-marker must be a non-negative integer"))
+		return
+	}
+
+	parts, err := loadUploadParts(bucketDir, upload.UploadID)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	result := ListPartsResult{
+		Bucket:           bucketName,
+		Key:              objectKey,
+		UploadID:         upload.UploadID,
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+	}
+	for _, part := range parts {
+		if part.PartNumber <= marker {
+			continue
+		}
+		if len(result.Parts) == maxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, PartItem{
+			PartNumber:   part.PartNumber,
+			LastModified: part.LastModified,
+			ETag:         part.ETag,
+			Size:         part.Size,
+		})
+		result.NextPartNumberMarker = part.PartNumber
+	}
+
+	s3error.WriteXML(w, r, http.StatusOK, result)
+}
+
+// ListMultipartUploadsHandler возвращает незавершенные загрузки ведра (GET /{bucket}?uploads)
+func ListMultipartUploadsHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	maxUploads, err := queryInt(query.Get("max-uploads"), defaultMaxKeys)
+	if err != nil {
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("max-uploads must be a non-negative integer"))
+		return
+	}
+
+	uploads, err := listMultipartUploads(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		return uploads[i].Initiated < uploads[j].Initiated
+	})
+
+	result := ListMultipartUploadsResult{Bucket: bucketName, Prefix: prefix, MaxUploads: maxUploads}
+	for _, upload := range uploads {
+		if !strings.HasPrefix(upload.Key, prefix) {
+			continue
+		}
+		if len(result.Uploads) == maxUploads {
+			result.IsTruncated = true
+			break
+		}
+		result.Uploads = append(result.Uploads, UploadItem{
+			Key:          upload.Key,
+			UploadID:     upload.UploadID,
+			Initiated:    upload.Initiated,
+			StorageClass: "STANDARD",
+		})
+	}
+
+	s3error.WriteXML(w, r, http.StatusOK, result)
+}
+
+// uploadEnvelope возвращает ключ данных загрузки и Envelope с новым IV
+// для очередной части или итогового объекта
+func uploadEnvelope(upload multipartUpload) (sse.Envelope, []byte, error) {
+	if !upload.Encryption.Encrypted() {
+		return sse.Envelope{}, nil, nil
+	}
+	key, err := dataKey(upload.Encryption)
+	if err != nil {
+		return sse.Envelope{}, nil, err
+	}
+	envelope := upload.Encryption
+	if envelope.IV, err = sse.NewIV(); err != nil {
+		return sse.Envelope{}, nil, err
+	}
+	return envelope, key, nil
+}
+
+// assembleParts склеивает части во временный файл в _tmp и вычисляет составной ETag.
+// Если задан key, части расшифровываются своими IV и шифруются заново с IV из envelope.
+// При ошибке временный файл удаляется.
+func assembleParts(bucketDir string, upload multipartUpload, parts []uploadPart, envelope sse.Envelope, key []byte) (tmpPath, etag string, size int64, err error) {
+	file, err := createTempObject(bucketDir)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer func() {
+		file.Close()
+		if err != nil {
+			os.Remove(file.Name())
+		}
+	}()
+	var out io.Writer = file
+	if key != nil {
+		stream, err := sse.NewStream(key, envelope.IV, 0)
+		if err != nil {
+			return "", "", 0, err
+		}
+		out = cipher.StreamWriter{S: stream, W: file}
+	}
+
+	// ETag составного объекта — MD5 от склеенных MD5 частей и число частей
+	digests := md5.New()
+	for _, part := range parts {
+		digest, err := hex.DecodeString(strings.Trim(part.ETag, `"`))
+		if err != nil {
+			return "", "", 0, fmt.Errorf("malformed ETag of part %d: %v", part.PartNumber, err)
+		}
+		digests.Write(digest)
+
+		in, err := os.Open(filepath.Join(uploadPath(bucketDir, upload.UploadID), strconv.Itoa(part.PartNumber)))
+		if err != nil {
+			return "", "", 0, fmt.Errorf("unable to open part %d: %v", part.PartNumber, err)
+		}
+		var data io.Reader = in
+		if key != nil {
+			stream, err := sse.NewStream(key, part.IV, 0)
+			if err != nil {
+				in.Close()
+				return "", "", 0, fmt.Errorf("unable to decrypt part %d: %v", part.PartNumber, err)
+			}
+			data = cipher.StreamReader{S: stream, R: in}
+		}
+		n, err := io.Copy(out, data)
+		in.Close()
+		if err != nil {
+			return "", "", 0, fmt.Errorf("unable to copy part %d: %v", part.PartNumber, err)
+		}
+		size += n
+	}
+	if err := file.Close(); err != nil {
+		return "", "", 0, fmt.Errorf("unable to write object file: %v", err)
+	}
+
+	etag = fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(digests.Sum(nil)), len(parts))
+	return file.Name(), etag, size, nil
+}
+
+// uploadPath возвращает каталог с частями загрузки
+func uploadPath(bucketDir, uploadID string) string {
+	return filepath.Join(bucketDir, multipartDirName, uploadID)
+}
+
+// newUploadID генерирует случайный идентификатор загрузки
+func newUploadID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// loadMultipartUploads читает все незавершенные загрузки из uploads.csv
+func loadMultipartUploads(bucketDir string) ([]multipartUpload, error) {
+	if _, err := os.Stat(filepath.Join(bucketDir, multipartDirName)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	records, err := readCSV(filepath.Join(bucketDir, multipartDirName, "uploads.csv"))
+	if err != nil {
+		return nil, err
+	}
+
+	uploads := make([]multipartUpload, 0, len(records))
+	for _, record := range records {
+		if len(record) < 5 {
+			return nil, fmt.Errorf("malformed multipart upload record: %v", record)
+		}
+		upload := multipartUpload{
+			UploadID:    record[0],
+			Bucket:      record[1],
+			Key:         record[2],
+			Initiated:   record[3],
+			ContentType: record[4],
+		}
+		if len(record) > 5 {
+			if upload.Metadata, err = decodeMetadata(record[5]); err != nil {
+				return nil, fmt.Errorf("malformed metadata of upload %s: %v", record[0], err)
+			}
+		}
+		if len(record) > 6 {
+			if upload.Tags, err = decodeMetadata(record[6]); err != nil {
+				return nil, fmt.Errorf("malformed tags of upload %s: %v", record[0], err)
+			}
+		}
+		if len(record) > 7 {
+			if upload.ACL, err = acl.Decode(record[7]); err != nil {
+				return nil, fmt.Errorf("malformed ACL of upload %s: %v", record[0], err)
+			}
+		}
+		if len(record) > 8 {
+			if upload.Encryption, err = sse.DecodeEnvelope(record[8]); err != nil {
+				return nil, fmt.Errorf("malformed encryption of upload %s: %v", record[0], err)
+			}
+		}
+		if len(record) > 9 {
+			if upload.Lock, err = decodeObjectLock(record[9]); err != nil {
+				return nil, fmt.Errorf("malformed object lock of upload %s: %v", record[0], err)
+			}
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, nil
+}
+
+// findMultipartUpload ищет загрузку по идентификатору. Собираемая загрузка не находится.
+func findMultipartUpload(bucketDir, uploadID string) (multipartUpload, bool, error) {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+	return findUploadLocked(bucketDir, uploadID)
+}
+
+// claimMultipartUpload находит загрузку ключа и отмечает ее как собираемую.
+// Отметку снимает releaseMultipartUpload.
+func claimMultipartUpload(bucketDir, bucketName, objectKey, uploadID string) (multipartUpload, bool, error) {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+
+	upload, found, err := findUploadLocked(bucketDir, uploadID)
+	if err != nil || !found || upload.Bucket != bucketName || upload.Key != objectKey {
+		return multipartUpload{}, false, err
+	}
+	completingUploads[uploadID] = true
+	return upload, true, nil
+}
+
+// releaseMultipartUpload снимает отметку о сборке; если сборка не удалась, загрузка снова доступна
+func releaseMultipartUpload(uploadID string) {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+	delete(completingUploads, uploadID)
+}
+
+// findUploadLocked ищет загрузку, которая сейчас не собирается; вызывающий держит multipartMu
+func findUploadLocked(bucketDir, uploadID string) (multipartUpload, bool, error) {
+	if uploadID == "" || completingUploads[uploadID] {
+		return multipartUpload{}, false, nil
+	}
+	uploads, err := loadMultipartUploads(bucketDir)
+	if err != nil {
+		return multipartUpload{}, false, err
+	}
+	for _, upload := range uploads {
+		if upload.UploadID == uploadID {
+			return upload, true, nil
+		}
+	}
+	return multipartUpload{}, false, nil
+}
+
+// listMultipartUploads возвращает загрузки ведра, кроме собираемых
+func listMultipartUploads(bucketDir, bucketName string) ([]multipartUpload, error) {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+
+	uploads, err := loadMultipartUploads(bucketDir)
+	if err != nil {
+		return nil, err
+	}
+	var result []multipartUpload
+	for _, upload := range uploads {
+		if upload.Bucket == bucketName && !completingUploads[upload.UploadID] {
+			result = append(result, upload)
+		}
+	}
+	return result, nil
+}
+
+// saveMultipartUpload добавляет загрузку в uploads.csv. Существование ведра проверяется
+// под multipartMu, чтобы загрузка не появилась у ведра, удаленного RemoveBucket.
+func saveMultipartUpload(bucketDir string, upload multipartUpload) error {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(bucketDir, upload.Bucket)); os.IsNotExist(err) {
+		return s3error.ErrNoSuchBucket
+	}
+
+	uploadsPath := filepath.Join(bucketDir, multipartDirName, "uploads.csv")
+	records, err := readCSV(uploadsPath)
+	if err != nil {
+		return err
+	}
+	records = append(records, formatMultipartUpload(upload))
+	return writeCSV(uploadsPath, records)
+}
+
+// writeMultipartUploads перезаписывает uploads.csv; вызывающий держит multipartMu
+func writeMultipartUploads(bucketDir string, uploads []multipartUpload) error {
+	records := make([][]string, 0, len(uploads))
+	for _, upload := range uploads {
+		records = append(records, formatMultipartUpload(upload))
+	}
+	return writeCSV(filepath.Join(bucketDir, multipartDirName, "uploads.csv"), records)
+}
+
+// formatMultipartUpload преобразует загрузку в строку uploads.csv
+func formatMultipartUpload(upload multipartUpload) []string {
+	return []string{
+		upload.UploadID,
+		upload.Bucket,
+		upload.Key,
+		upload.Initiated,
+		upload.ContentType,
+		encodeMetadata(upload.Metadata),
+		encodeMetadata(upload.Tags),
+		upload.ACL.Encode(),
+		upload.Encryption.Encode(),
+		upload.Lock.encode(),
+	}
+}
+
+// removeMultipartUpload отменяет загрузку: удаляет ее из uploads.csv вместе с частями.
+// Собираемую загрузку отменить нельзя — возвращается NoSuchUpload.
+func removeMultipartUpload(bucketDir, uploadID string) error {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+
+	if completingUploads[uploadID] {
+		return s3error.ErrNoSuchUpload
+	}
+	return deleteMultipartUpload(bucketDir, uploadID)
+}
+
+// finishMultipartUpload удаляет собранную загрузку вместе с частями
+func finishMultipartUpload(bucketDir, uploadID string) error {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+	return deleteMultipartUpload(bucketDir, uploadID)
+}
+
+// deleteMultipartUpload удаляет загрузку из uploads.csv и ее части; вызывающий держит multipartMu
+func deleteMultipartUpload(bucketDir, uploadID string) error {
+	uploadsPath := filepath.Join(bucketDir, multipartDirName, "uploads.csv")
+	records, err := readCSV(uploadsPath)
+	if err != nil {
+		return err
+	}
+
+	var updatedRecords [][]string
+	for _, record := range records {
+		if record[0] != uploadID {
+			updatedRecords = append(updatedRecords, record)
+		}
+	}
+	if err := writeCSV(uploadsPath, updatedRecords); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(uploadPath(bucketDir, uploadID)); err != nil {
+		return fmt.Errorf("unable to remove upload parts: %v", err)
+	}
+	return nil
+}
+
+// loadUploadParts читает список частей загрузки, отсортированный по номеру
+func loadUploadParts(bucketDir, uploadID string) ([]uploadPart, error) {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+
+	records, err := readCSV(filepath.Join(uploadPath(bucketDir, uploadID), "parts.csv"))
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]uploadPart, 0, len(records))
+	for _, record := range records {
+		part, err := parseUploadPart(record)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+// parseUploadPart разбирает строку parts.csv
+func parseUploadPart(record []string) (uploadPart, error) {
+	if len(record) < 4 {
+		return uploadPart{}, fmt.Errorf("malformed upload part record: %v", record)
+	}
+	partNumber, err := strconv.Atoi(record[0])
+	if err != nil {
+		return uploadPart{}, fmt.Errorf("malformed part number %q: %v", record[0], err)
+	}
+	size, err := strconv.ParseInt(record[2], 10, 64)
+	if err != nil {
+		return uploadPart{}, fmt.Errorf("malformed part size %q: %v", record[2], err)
+	}
+	part := uploadPart{PartNumber: partNumber, ETag: record[1], Size: size, LastModified: record[3]}
+	if len(record) > 4 && record[4] != "" {
+		if part.IV, err = base64.StdEncoding.DecodeString(record[4]); err != nil {
+			return uploadPart{}, fmt.Errorf("malformed IV of part %d: %v", partNumber, err)
+		}
+	}
+	return part, nil
+}
+
+// saveUploadPart добавляет или заменяет часть в parts.csv. Части собираемой загрузки не меняются.
+func saveUploadPart(bucketDir, uploadID string, part uploadPart) error {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+
+	if completingUploads[uploadID] {
+		return s3error.ErrNoSuchUpload
+	}
+
+	partsPath := filepath.Join(uploadPath(bucketDir, uploadID), "parts.csv")
+	records, err := readCSV(partsPath)
+	if err != nil {
+		return err
+	}
+
+	number := strconv.Itoa(part.PartNumber)
+	var updatedRecords [][]string
+	for _, record := range records {
+		if record[0] != number {
+			updatedRecords = append(updatedRecords, record)
+		}
+	}
+	iv := ""
+	if part.IV != nil {
+		iv = base64.StdEncoding.EncodeToString(part.IV)
+	}
+	updatedRecords = append(updatedRecords, []string{number, part.ETag, strconv.FormatInt(part.Size, 10), part.LastModified, iv})
+	return writeCSV(partsPath, updatedRecords)
+}
@@ -0,0 +1,494 @@
+package object
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"triple-s/pkg/acl"
+	"triple-s/pkg/auth"
+	"triple-s/pkg/policy"
+	"triple-s/pkg/s3error"
+)
+
+// Object Lock (WORM): версии объектов под удержанием нельзя удалить или перезаписать.
+// Блокировка включается при создании ведра или для ведра с включенным версионированием
+// и не отключается; версионирование такого ведра нельзя приостановить.
+const (
+	objectLockFileName = "object-lock.xml"
+	// maxObjectLockBodySize ограничивает размер тел PutObjectLockConfiguration, PutObjectRetention и PutObjectLegalHold
+	maxObjectLockBodySize = 16 << 10
+
+	objectLockEnabled  = "Enabled"
+	lockModeGovernance = "GOVERNANCE"
+	lockModeCompliance = "COMPLIANCE"
+	legalHoldOn        = "ON"
+	legalHoldOff       = "OFF"
+
+	// bypassGovernanceHeader разрешает сократить или снять удержание GOVERNANCE
+	bypassGovernanceHeader = "x-amz-bypass-governance-retention"
+)
+
+// errObjectLocked — отказ в удалении или перезаписи версии под удержанием
+var errObjectLocked = s3error.ErrAccessDenied.WithMessage("Access Denied because object protected by object lock.")
+
+// errObjectLockMissing — запрос удержания в ведре без Object Lock
+var errObjectLockMissing = s3error.ErrInvalidRequest.WithMessage("Bucket is missing Object Lock Configuration")
+
+// ObjectLockConfiguration — тело PutObjectLockConfiguration и ответ GetObjectLockConfiguration
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled,omitempty"`
+	Rule              *ObjectLockRule `xml:"Rule,omitempty"`
+}
+
+// ObjectLockRule — удержание по умолчанию для новых версий
+type ObjectLockRule struct {
+	DefaultRetention DefaultRetention `xml:"DefaultRetention"`
+}
+
+// DefaultRetention — режим и срок удержания по умолчанию; задается ровно одно из Days и Years
+type DefaultRetention struct {
+	Mode  string `xml:"Mode"`
+	Days  int    `xml:"Days,omitempty"`
+	Years int    `xml:"Years,omitempty"`
+}
+
+// ObjectLockRetention — тело PutObjectRetention и ответ GetObjectRetention
+type ObjectLockRetention struct {
+	XMLName         xml.Name
+	Mode            string `xml:"Mode,omitempty"`
+	RetainUntilDate string `xml:"RetainUntilDate,omitempty"`
+}
+
+// ObjectLockLegalHold — тело PutObjectLegalHold и ответ GetObjectLegalHold
+type ObjectLockLegalHold struct {
+	XMLName xml.Name
+	Status  string `xml:"Status"`
+}
+
+// objectLock — удержание версии объекта. Пустой Mode означает, что срок удержания не задан.
+type objectLock struct {
+	Mode        string
+	RetainUntil time.Time
+	LegalHold   bool
+}
+
+// retained сообщает, что срок удержания еще не истек
+func (l objectLock) retained(now time.Time) bool {
+	return l.Mode != "" && now.Before(l.RetainUntil)
+}
+
+// checkRemoval запрещает удалять или перезаписывать версию под юридическим удержанием
+// или до конца срока удержания. GOVERNANCE снимается разрешенным обходом.
+func (l objectLock) checkRemoval(now time.Time, bypassGovernance bool) error {
+	if l.LegalHold {
+		return errObjectLocked
+	}
+	if l.retained(now) && (l.Mode == lockModeCompliance || !bypassGovernance) {
+		return errObjectLocked
+	}
+	return nil
+}
+
+// checkRetentionChange проверяет замену срока удержания на next: COMPLIANCE можно только продлить,
+// GOVERNANCE без обхода — продлить или перевести в COMPLIANCE
+func (l objectLock) checkRetentionChange(next objectLock, now time.Time, bypassGovernance bool) error {
+	if !l.retained(now) {
+		return nil
+	}
+	weakened := next.Mode == "" || next.RetainUntil.Before(l.RetainUntil)
+	if l.Mode == lockModeCompliance && (weakened || next.Mode != lockModeCompliance) {
+		return errObjectLocked
+	}
+	if weakened && !bypassGovernance {
+		return errObjectLocked
+	}
+	return nil
+}
+
+// encode сериализует удержание в строку вида mode=GOVERNANCE&until=...&hold=ON для хранения в CSV
+func (l objectLock) encode() string {
+	values := url.Values{}
+	if l.Mode != "" {
+		values.Set("mode", l.Mode)
+		values.Set("until", l.RetainUntil.UTC().Format(time.RFC3339))
+	}
+	if l.LegalHold {
+		values.Set("hold", legalHoldOn)
+	}
+	return values.Encode()
+}
+
+// decodeObjectLock разбирает строку, созданную encode
+func decodeObjectLock(encoded string) (objectLock, error) {
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		return objectLock{}, err
+	}
+	lock := objectLock{Mode: values.Get("mode"), LegalHold: values.Get("hold") == legalHoldOn}
+	if lock.Mode != "" {
+		if lock.RetainUntil, err = time.Parse(time.RFC3339, values.Get("until")); err != nil {
+			return objectLock{}, err
+		}
+	}
+	return lock, nil
+}
+
+// parseRetention проверяет режим и дату окончания удержания; они задаются вместе или не задаются
+func parseRetention(mode, retainUntil string, now time.Time) (objectLock, error) {
+	if mode == "" && retainUntil == "" {
+		return objectLock{}, nil
+	}
+	if mode == "" || retainUntil == "" {
+		return objectLock{}, s3error.ErrInvalidArgument.WithMessage("x-amz-object-lock-retain-until-date and x-amz-object-lock-mode must both be supplied")
+	}
+	if mode != lockModeGovernance && mode != lockModeCompliance {
+		return objectLock{}, s3error.ErrInvalidArgument.WithMessage("Unknown wormMode directive.")
+	}
+	until, err := time.Parse(time.RFC3339, retainUntil)
+	if err != nil {
+		return objectLock{}, s3error.ErrInvalidArgument.WithMessage("The retain until date must be provided in ISO 8601 format")
+	}
+	if !until.After(now) {
+		return objectLock{}, s3error.ErrInvalidArgument.WithMessage("The retain until date must be in the future!")
+	}
+	return objectLock{Mode: mode, RetainUntil: until.UTC()}, nil
+}
+
+// until возвращает окончание удержания по умолчанию для версии, созданной в момент now
+func (d DefaultRetention) until(now time.Time) time.Time {
+	return now.AddDate(d.Years, 0, d.Days).UTC()
+}
+
+// loadObjectLock возвращает конфигурацию Object Lock ведра и признак того, что блокировка включена
+func loadObjectLock(bucketDir, bucketName string) (ObjectLockConfiguration, bool, error) {
+	data, err := os.ReadFile(filepath.Join(bucketDir, bucketName, objectLockFileName))
+	if os.IsNotExist(err) {
+		return ObjectLockConfiguration{}, false, nil
+	} else if err != nil {
+		return ObjectLockConfiguration{}, false, fmt.Errorf("unable to read object lock configuration: %v", err)
+	}
+	var config ObjectLockConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return ObjectLockConfiguration{}, false, fmt.Errorf("malformed object lock configuration: %v", err)
+	}
+	return config, config.ObjectLockEnabled == objectLockEnabled, nil
+}
+
+// saveObjectLock записывает конфигурацию Object Lock ведра
+func saveObjectLock(bucketDir, bucketName string, config ObjectLockConfiguration) error {
+	config.XMLName = xml.Name{Local: "ObjectLockConfiguration"}
+	data, err := xml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("unable to encode object lock configuration: %v", err)
+	}
+	if err := writeBucketConfig(bucketDir, bucketName, objectLockFileName, data); err != nil {
+		return fmt.Errorf("unable to save object lock configuration: %v", err)
+	}
+	return nil
+}
+
+// EnableObjectLock включает Object Lock и версионирование нового ведра
+// (CreateBucket с x-amz-bucket-object-lock-enabled: true)
+func EnableObjectLock(bucketDir, bucketName string) error {
+	data, err := xml.Marshal(VersioningConfiguration{Status: versioningEnabled})
+	if err != nil {
+		return fmt.Errorf("unable to encode versioning configuration: %v", err)
+	}
+	if err := writeBucketConfig(bucketDir, bucketName, versioningFileName, data); err != nil {
+		return fmt.Errorf("unable to save versioning configuration: %v", err)
+	}
+	return saveObjectLock(bucketDir, bucketName, ObjectLockConfiguration{ObjectLockEnabled: objectLockEnabled})
+}
+
+// PutObjectLockConfigurationHandler включает Object Lock и задает удержание по умолчанию
+// (PUT /{bucket}?object-lock). Включить блокировку можно только при включенном версионировании.
+func PutObjectLockConfigurationHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	var config ObjectLockConfiguration
+	if err := readXMLBody(r, maxObjectLockBodySize, &config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if config.ObjectLockEnabled != objectLockEnabled {
+		s3error.WriteError(w, r, s3error.ErrMalformedXML)
+		return
+	}
+	if config.Rule != nil {
+		retention := config.Rule.DefaultRetention
+		if retention.Mode != lockModeGovernance && retention.Mode != lockModeCompliance {
+			s3error.WriteError(w, r, s3error.ErrMalformedXML)
+			return
+		}
+		if (retention.Days == 0) == (retention.Years == 0) {
+			s3error.WriteError(w, r, s3error.ErrMalformedXML)
+			return
+		}
+		if retention.Days < 0 || retention.Years < 0 {
+			s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("Default retention period must be a positive integer value"))
+			return
+		}
+	}
+
+	// Под metadataMu, чтобы версионирование не приостановили между проверкой и записью
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+	if _, enabled, err := loadObjectLock(bucketDir, bucketName); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	} else if !enabled {
+		versioning, err := loadVersioning(bucketDir, bucketName)
+		if err != nil {
+			s3error.WriteError(w, r, err)
+			return
+		}
+		if versioning != versioningEnabled {
+			s3error.WriteError(w, r, s3error.ErrInvalidBucketState.WithMessage("Versioning must be 'Enabled' on the bucket to apply a Object Lock configuration"))
+			return
+		}
+	}
+	if err := saveObjectLock(bucketDir, bucketName, config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetObjectLockConfigurationHandler возвращает конфигурацию Object Lock (GET /{bucket}?object-lock)
+func GetObjectLockConfigurationHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	config, enabled, err := loadObjectLock(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !enabled {
+		s3error.WriteError(w, r, s3error.ErrNoObjectLockConfig)
+		return
+	}
+	config.XMLName = s3error.XMLName("ObjectLockConfiguration")
+	s3error.WriteXML(w, r, http.StatusOK, config)
+}
+
+// PutObjectRetentionHandler задает срок удержания версии объекта (PUT /{bucket}/{key}?retention).
+// Пустое тело Retention снимает удержание GOVERNANCE при разрешенном обходе.
+func PutObjectRetentionHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	if err := requireObjectLock(bucketDir, bucketName); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	var retention ObjectLockRetention
+	if err := readXMLBody(r, maxObjectLockBodySize, &retention); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	now := time.Now()
+	next, err := parseRetention(retention.Mode, retention.RetainUntilDate, now)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	bypass, err := canBypassGovernance(r, bucketDir, bucketName, objectKey)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	record, err := updateObjectVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"), func(record *objectRecord) error {
+		if err := record.Lock.checkRetentionChange(next, now, bypass); err != nil {
+			return err
+		}
+		record.Lock.Mode, record.Lock.RetainUntil = next.Mode, next.RetainUntil
+		return nil
+	})
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetObjectRetentionHandler возвращает срок удержания версии объекта (GET /{bucket}/{key}?retention)
+func GetObjectRetentionHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	if err := requireObjectLock(bucketDir, bucketName); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	record, _, err := lookupVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"))
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if record.Lock.Mode == "" {
+		s3error.WriteError(w, r, s3error.ErrNoSuchObjectLock)
+		return
+	}
+	s3error.WriteXML(w, r, http.StatusOK, ObjectLockRetention{
+		XMLName:         s3error.XMLName("Retention"),
+		Mode:            record.Lock.Mode,
+		RetainUntilDate: record.Lock.RetainUntil.Format(time.RFC3339),
+	})
+}
+
+// PutObjectLegalHoldHandler ставит или снимает юридическое удержание версии объекта
+// (PUT /{bucket}/{key}?legal-hold). Удержание действует без срока, пока его не снимут.
+func PutObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	if err := requireObjectLock(bucketDir, bucketName); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	var legalHold ObjectLockLegalHold
+	if err := readXMLBody(r, maxObjectLockBodySize, &legalHold); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if legalHold.Status != legalHoldOn && legalHold.Status != legalHoldOff {
+		s3error.WriteError(w, r, s3error.ErrMalformedXML)
+		return
+	}
+
+	record, err := updateObjectVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"), func(record *objectRecord) error {
+		record.Lock.LegalHold = legalHold.Status == legalHoldOn
+		return nil
+	})
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetObjectLegalHoldHandler возвращает юридическое удержание версии объекта (GET /{bucket}/{key}?legal-hold)
+func GetObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	if err := requireObjectLock(bucketDir, bucketName); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	record, _, err := lookupVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"))
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	status := legalHoldOff
+	if record.Lock.LegalHold {
+		status = legalHoldOn
+	}
+	s3error.WriteXML(w, r, http.StatusOK, ObjectLockLegalHold{
+		XMLName: s3error.XMLName("LegalHold"),
+		Status:  status,
+	})
+}
+
+// requireObjectLock проверяет, что ведро существует и в нем включен Object Lock
+func requireObjectLock(bucketDir, bucketName string) error {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		return s3error.ErrNoSuchBucket
+	}
+	_, enabled, err := loadObjectLock(bucketDir, bucketName)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return errObjectLockMissing
+	}
+	return nil
+}
+
+// requestObjectLock читает удержание новой версии из x-amz-object-lock-*. Если режим
+// не задан, применяется удержание ведра по умолчанию.
+func requestObjectLock(r *http.Request, bucketDir, bucketName string) (objectLock, error) {
+	mode := r.Header.Get("x-amz-object-lock-mode")
+	retainUntil := r.Header.Get("x-amz-object-lock-retain-until-date")
+	legalHold := r.Header.Get("x-amz-object-lock-legal-hold")
+
+	config, enabled, err := loadObjectLock(bucketDir, bucketName)
+	if err != nil {
+		return objectLock{}, err
+	}
+	if !enabled {
+		if mode != "" || retainUntil != "" || legalHold != "" {
+			return objectLock{}, errObjectLockMissing
+		}
+		return objectLock{}, nil
+	}
+
+	now := time.Now()
+	lock, err := parseRetention(mode, retainUntil, now)
+	if err != nil {
+		return objectLock{}, err
+	}
+	switch legalHold {
+	case "", legalHoldOff:
+	case legalHoldOn:
+		lock.LegalHold = true
+	default:
+		return objectLock{}, s3error.ErrInvalidArgument.WithMessage("Legal Hold must be either of 'ON' or 'OFF'")
+	}
+	if lock.Mode == "" && config.Rule != nil {
+		lock.Mode = config.Rule.DefaultRetention.Mode
+		lock.RetainUntil = config.Rule.DefaultRetention.until(now)
+	}
+	return lock, nil
+}
+
+// canBypassGovernance сообщает, что запрос может обойти удержание GOVERNANCE: он содержит
+// x-amz-bypass-governance-retention: true, а политика разрешает s3:BypassGovernanceRetention
+// или отправитель владеет ведром. Без аутентификации обход разрешен, если политика его не запрещает.
+func canBypassGovernance(r *http.Request, bucketDir, bucketName, objectKey string) (bool, error) {
+	if !strings.EqualFold(r.Header.Get(bypassGovernanceHeader), "true") {
+		return false, nil
+	}
+	decision := policy.NotApplicable
+	if check, ok := policy.CheckerFromContext(r.Context()); ok {
+		decision = check("s3:BypassGovernanceRetention", policy.ObjectARN(bucketName, objectKey))
+	}
+	switch decision {
+	case policy.Deny:
+		return false, nil
+	case policy.Allow:
+		return true, nil
+	}
+
+	identity, enforced := auth.IdentityFromContext(r.Context())
+	if !enforced {
+		return true, nil
+	}
+	bucketACL, err := acl.LoadBucket(bucketDir, bucketName)
+	if err != nil {
+		return false, err
+	}
+	return bucketACL.IsOwner(identity.AccessKey), nil
+}
+
+// setObjectLockHeaders сообщает клиенту удержание версии объекта
+func setObjectLockHeaders(w http.ResponseWriter, lock objectLock) {
+	if lock.Mode != "" {
+		w.Header().Set("x-amz-object-lock-mode", lock.Mode)
+		w.Header().Set("x-amz-object-lock-retain-until-date", lock.RetainUntil.Format(time.RFC3339))
+	}
+	if lock.LegalHold {
+		w.Header().Set("x-amz-object-lock-legal-hold", legalHoldOn)
+	}
+}
@@ -0,0 +1,100 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"triple-s/pkg/policy"
+	"triple-s/pkg/s3error"
+)
+
+const (
+	policyFileName = "policy.json"
+	// maxPolicySize — предельный размер политики ведра (как в S3)
+	maxPolicySize = 20 << 10
+)
+
+// PutBucketPolicyHandler сохраняет политику доступа ведра (PUT /{bucket}?policy)
+func PutBucketPolicyHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxPolicySize+1))
+	if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to read request body: %w", err))
+		return
+	}
+	if len(data) > maxPolicySize {
+		s3error.WriteError(w, r, s3error.ErrMalformedPolicy.WithMessage("Policy exceeds the maximum allowed document size."))
+		return
+	}
+	if _, err := policy.Parse(data, bucketName); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	// Документ хранится как есть: GetBucketPolicy возвращает его без изменений
+	if err := writeBucketConfig(bucketDir, bucketName, policyFileName, data); err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to save bucket policy: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBucketPolicyHandler возвращает политику доступа ведра (GET /{bucket}?policy)
+func GetBucketPolicyHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(bucketPath, policyFileName))
+	if os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucketPolicy)
+		return
+	} else if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to read bucket policy: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// DeleteBucketPolicyHandler удаляет политику доступа ведра (DELETE /{bucket}?policy)
+func DeleteBucketPolicyHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	if err := os.Remove(filepath.Join(bucketPath, policyFileName)); err != nil && !os.IsNotExist(err) {
+		s3error.WriteError(w, r, fmt.Errorf("unable to delete bucket policy: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LoadBucketPolicy читает политику доступа ведра; nil означает, что политики нет
+func LoadBucketPolicy(bucketDir, bucketName string) (*policy.Policy, error) {
+	data, err := os.ReadFile(filepath.Join(bucketDir, bucketName, policyFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read bucket policy: %v", err)
+	}
+	bucketPolicy, err := policy.Parse(data, bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("stored policy of bucket %s is invalid: %v", bucketName, err)
+	}
+	return bucketPolicy, nil
+}
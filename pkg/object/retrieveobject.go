@@ -1,70 +1,181 @@
 package object
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"triple-s/pkg/s3error"
 )
 
+// errRangeNotSatisfiable означает, что запрошенный диапазон лежит за пределами объекта
+var errRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
 // RetrieveObjectHandler обрабатывает запрос на получение объекта из бакета.
 func RetrieveObjectHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
 	if bucketName == "" || objectKey == "" {
-		http.Error(w, "400 Bad Request: Missing bucket name or object key", http.StatusBadRequest)
+		s3error.WriteError(w, r, s3error.ErrInvalidURI.WithMessage("missing bucket name or object key"))
 		return
 	}
 
 	// 2. Проверка существования ведра
 	bucketPath := filepath.Join(bucketDir, bucketName)
 	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
-		http.Error(w, "404 Not Found: Bucket does not exist", http.StatusNotFound)
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
 		return
 	}
 
-	// 3. Проверка существования объекта
-	objectPath := filepath.Join(bucketPath, objectKey)
-	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-		http.Error(w, "404 Not Found: Object does not exist", http.StatusNotFound)
+	// 3. Метаданные запрошенной версии объекта (Content-Type, ETag, Last-Modified)
+	record, file, err := openVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"))
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
 		return
 	}
+	defer file.Close()
 
-	// 4. Определяем Content-Type на основе расширения файла
-	contentType := http.DetectContentType([]byte{})
-	ext := filepath.Ext(objectKey)
-	if ext == ".png" {
-		contentType = "image/png"
-	} else if ext == ".jpg" || ext == ".jpeg" {
-		contentType = "image/jpeg"
-	} else if ext == ".txt" {
-		contentType = "text/plain"
-	} else {
-		contentType = "application/octet-stream" // по умолчанию для неизвестных типов
+	// 4. Отдача данных с учетом ключа клиента (SSE-C), условных заголовков и Range
+	if err := writeObject(w, r, record, file, http.StatusOK); err != nil {
+		s3error.WriteError(w, r, err)
 	}
+}
 
-	// 5. Чтение содержимого объекта
-	file, err := os.Open(objectPath)
+// writeObject отдает данные версии объекта из файла, открытого openVersion. Ошибки, возникшие до начала ответа, возвращаются,
+// чтобы вызывающий записал их в своем формате. При статусе, отличном от 200 (страница ошибки
+// сайта), условные заголовки и Range не учитываются.
+func writeObject(w http.ResponseWriter, r *http.Request, record objectRecord, file *os.File, status int) error {
+	// 1. Проверка ключа клиента (SSE-C)
+	key, err := readKey(r.Header, customerKeyHeaderPrefix, record.Encryption)
 	if err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to open object", http.StatusInternalServerError)
-		return
+		return err
 	}
-	defer file.Close()
 
-	// Получаем информацию о файле
 	fileInfo, err := file.Stat()
 	if err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to get object info", http.StatusInternalServerError)
-		return
+		return fmt.Errorf("unable to get object info: %v", err)
 	}
+	// Размер берем из файловой системы, чтобы не отдать больше, чем есть на диске
+	record.Size = fileInfo.Size()
 
-	// Читаем содержимое файла в память
-	data := make([]byte, fileInfo.Size())
-	_, err = file.Read(data)
+	// 2. Проверка условных заголовков
+	start, length := int64(0), record.Size
+	partial := false
+	if status == http.StatusOK {
+		if notModified, err := requestConditions(r.Header).check(record); err != nil {
+			return err
+		} else if notModified {
+			writeNotModified(w, record)
+			return nil
+		}
+
+		// 3. Разбор заголовка Range с учетом If-Range
+		if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && ifRangeMatches(r, record) {
+			rangeStart, rangeLength, ok, err := parseRange(rangeHeader, record.Size)
+			if err != nil {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", record.Size))
+				return s3error.ErrInvalidRange
+			}
+			if ok {
+				start, length, partial = rangeStart, rangeLength, true
+			}
+		}
+	}
+
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to read object: %v", err)
+	}
+	// Зашифрованные данные расшифровываются с позиции start без чтения предыдущих байт
+	data, err := decryptReader(file, record.Encryption, key, start)
 	if err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to read object", http.StatusInternalServerError)
-		return
+		return err
+	}
+
+	// 4. Устанавливаем заголовки и потоково отдаем данные объекта
+	setObjectHeaders(w, record)
+	setEncryptionHeaders(w, r, record.Encryption)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	if partial {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, record.Size))
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+	io.CopyN(w, data, length)
+	return nil
+}
+
+// parseRange разбирает заголовок Range вида "bytes=a-b", "bytes=a-" или "bytes=-n".
+// ok == false означает, что заголовок следует проигнорировать и отдать объект целиком.
+func parseRange(header string, size int64) (start, length int64, ok bool, err error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		// Неизвестные единицы и множественные диапазоны не поддерживаются
+		return 0, 0, false, nil
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, nil
+	}
+
+	// Суффиксный диапазон: последние n байт
+	if first == "" {
+		n, parseErr := strconv.ParseInt(last, 10, 64)
+		if parseErr != nil || n < 0 {
+			return 0, 0, false, nil
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, true, nil
+	}
+
+	start, parseErr := strconv.ParseInt(first, 10, 64)
+	if parseErr != nil || start < 0 {
+		return 0, 0, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		end, parseErr = strconv.ParseInt(last, 10, 64)
+		if parseErr != nil || end < start {
+			return 0, 0, false, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
 	}
+	if start >= size {
+		return 0, 0, false, errRangeNotSatisfiable
+	}
+	return start, end - start + 1, true, nil
+}
 
-	// 6. Устанавливаем заголовки и возвращаем данные объекта
-	w.Header().Set("Content-Type", contentType)
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+// ifRangeMatches проверяет условие If-Range: диапазон применяется,
+// только если валидатор совпадает с текущим ETag или датой изменения
+func ifRangeMatches(r *http.Request, record objectRecord) bool {
+	validator := r.Header.Get("If-Range")
+	if validator == "" {
+		return true
+	}
+	if strings.HasPrefix(validator, `"`) || strings.HasPrefix(validator, `W/`) {
+		// Для If-Range допускается только строгое сравнение ETag
+		return record.ETag != "" && validator == record.ETag
+	}
+	since, err := http.ParseTime(validator)
+	if err != nil {
+		return false
+	}
+	modified, err := time.Parse(time.RFC3339, record.LastModified)
+	if err != nil {
+		return false
+	}
+	return modified.Truncate(time.Second).Equal(since)
 }
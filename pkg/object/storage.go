@@ -0,0 +1,174 @@
+package object
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"triple-s/pkg/s3error"
+)
+
+// serviceDirPrefix начинает имена служебных каталогов в dataDir (см. reservedNames в пакете bucket)
+const serviceDirPrefix = "_"
+
+// tmpDirName — каталог для незавершенных записей
+const tmpDirName = serviceDirPrefix + "tmp"
+
+// isBucketDir сообщает, является ли запись dataDir каталогом ведра, а не служебным каталогом
+func isBucketDir(entry os.DirEntry) bool {
+	return entry.IsDir() && !strings.HasPrefix(entry.Name(), serviceDirPrefix)
+}
+
+// objectPath возвращает путь к файлу с данными объекта.
+// Ключ может содержать '/', "..", символы Unicode и быть длиннее допустимого имени файла,
+// поэтому на диске объект хранится под SHA-256 от ключа: такое имя всегда безопасно
+// и не пересекается с objects.csv и служебными файлами ведра.
+func objectPath(bucketDir, bucketName, objectKey string) string {
+	sum := sha256.Sum256([]byte(objectKey))
+	return filepath.Join(bucketDir, bucketName, hex.EncodeToString(sum[:]))
+}
+
+// RemoveBucket удаляет каталог пустого ведра. Проверка и удаление выполняются под multipartMu
+// и metadataMu, поэтому параллельная запись объекта или начало составной загрузки либо
+// завершаются до проверки, либо получают NoSuchBucket. Учитываются и предыдущие версии,
+// поэтому ведро с версиями под удержанием Object Lock удалить нельзя, пока их не удалят по одной.
+// Ведро с незавершенными составными загрузками тоже считается непустым.
+func RemoveBucket(dataDir, bucketName string) error {
+	multipartMu.Lock()
+	defer multipartMu.Unlock()
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+
+	idx, err := loadBucketIndex(dataDir, bucketName)
+	if err != nil {
+		return err
+	}
+	if len(idx.current) > 0 || len(idx.versions) > 0 {
+		return s3error.ErrBucketNotEmpty
+	}
+	uploads, err := loadMultipartUploads(dataDir)
+	if err != nil {
+		return err
+	}
+	for _, upload := range uploads {
+		if upload.Bucket == bucketName {
+			return s3error.ErrBucketNotEmpty
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(dataDir, bucketName)); err != nil {
+		return fmt.Errorf("error deleting bucket directory: %v", err)
+	}
+	return nil
+}
+
+// MigrateObjectLayout переносит файлы объектов, сохраненные под исходным именем ключа,
+// в раскладку objectPath. Вызывается при старте сервера; повторный запуск ничего не меняет.
+func MigrateObjectLayout(dataDir string) error {
+	entries, err := os.ReadDir(dataDir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read data directory: %v", err)
+	}
+
+	for _, entry := range entries {
+		if !isBucketDir(entry) {
+			continue
+		}
+		records, err := loadObjectRecords(dataDir, entry.Name())
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if record.Key == "objects.csv" || filepath.Base(record.Key) != record.Key {
+				continue
+			}
+			legacyPath := filepath.Join(dataDir, entry.Name(), record.Key)
+			targetPath := objectPath(dataDir, entry.Name(), record.Key)
+			if _, err := os.Stat(targetPath); err == nil {
+				continue
+			}
+			info, err := os.Stat(legacyPath)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if err := os.Rename(legacyPath, targetPath); err != nil {
+				return fmt.Errorf("unable to migrate object %s/%s: %v", entry.Name(), record.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeTempObject потоково записывает данные во временный файл и возвращает его путь и размер.
+// Данные дополнительно передаются во все writers (например, для подсчета хешей).
+// При ошибке временный файл удаляется; при успехе вызывающий перемещает его на место.
+func writeTempObject(bucketDir string, body io.Reader, writers ...io.Writer) (string, int64, error) {
+	file, err := createTempObject(bucketDir)
+	if err != nil {
+		return "", 0, err
+	}
+
+	size, err := io.Copy(io.MultiWriter(append([]io.Writer{file}, writers...)...), body)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", 0, fmt.Errorf("unable to write object data: %w", err)
+	}
+	return file.Name(), size, nil
+}
+
+// createTempObject создает временный файл с уникальным именем в каталоге _tmp
+func createTempObject(bucketDir string) (*os.File, error) {
+	tmpDir := filepath.Join(bucketDir, tmpDirName)
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create temporary directory: %v", err)
+	}
+	file, err := os.CreateTemp(tmpDir, "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file: %v", err)
+	}
+	return file, nil
+}
+
+// commitTempObject атомарно заменяет файл назначения временным файлом
+func commitTempObject(tmpPath, targetPath string) error {
+	if err := os.Rename(tmpPath, targetPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("unable to move object into place: %v", err)
+	}
+	return nil
+}
+
+// writeBucketConfig атомарно записывает файл конфигурации ведра
+func writeBucketConfig(bucketDir, bucketName, fileName string, data []byte) error {
+	return writeFileAtomic(filepath.Join(bucketDir, bucketName, fileName), data)
+}
+
+// writeFileAtomic записывает файл через временный файл в том же каталоге и переименование,
+// чтобы фоновые задачи и запросы, читающие без блокировки, не увидели его пустым
+// или наполовину записанным
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+package object
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"triple-s/pkg/s3error"
+)
+
+// Ограничения тегов S3
+const (
+	taggingFileName = "tagging.xml"
+	maxObjectTags   = 10
+	maxBucketTags   = 50
+	maxTagKeyLen    = 128
+	maxTagValueLen  = 256
+	// maxTaggingBodySize ограничивает размер тела PutObjectTagging и PutBucketTagging
+	maxTaggingBodySize = 64 << 10
+)
+
+// Tagging — тело PutObjectTagging/PutBucketTagging и ответ GetObjectTagging/GetBucketTagging
+type Tagging struct {
+	XMLName xml.Name
+	TagSet  TagSet `xml:"TagSet"`
+}
+
+// TagSet — набор тегов
+type TagSet struct {
+	Tags []Tag `xml:"Tag"`
+}
+
+// Tag — пара ключ-значение
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// PutObjectTaggingHandler заменяет теги объекта (PUT /{bucket}/{key}?tagging)
+func PutObjectTaggingHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	var tagging Tagging
+	if err := readXMLBody(r, maxTaggingBodySize, &tagging); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	tags, err := validateTags(tagging.TagSet.Tags, maxObjectTags)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	record, err := updateObjectVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"), func(record *objectRecord) error {
+		record.Tags = tags
+		return nil
+	})
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if record.VersionID != "" {
+		w.Header().Set("x-amz-version-id", record.VersionID)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetObjectTaggingHandler возвращает теги объекта (GET /{bucket}/{key}?tagging)
+func GetObjectTaggingHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	record, _, err := lookupVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"))
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if record.VersionID != "" {
+		w.Header().Set("x-amz-version-id", record.VersionID)
+	}
+	s3error.WriteXML(w, r, http.StatusOK, newTagging(record.Tags))
+}
+
+// DeleteObjectTaggingHandler удаляет все теги объекта (DELETE /{bucket}/{key}?tagging)
+func DeleteObjectTaggingHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
+	record, err := updateObjectVersion(bucketDir, bucketName, objectKey, r.URL.Query().Get("versionId"), func(record *objectRecord) error {
+		record.Tags = nil
+		return nil
+	})
+	if err != nil {
+		setDeleteMarkerHeaders(w, record)
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if record.VersionID != "" {
+		w.Header().Set("x-amz-version-id", record.VersionID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PutBucketTaggingHandler заменяет теги ведра (PUT /{bucket}?tagging)
+func PutBucketTaggingHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	var tagging Tagging
+	if err := readXMLBody(r, maxTaggingBodySize, &tagging); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	tags, err := validateTags(tagging.TagSet.Tags, maxBucketTags)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	data, err := xml.Marshal(newTagging(tags))
+	if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to encode bucket tagging: %v", err))
+		return
+	}
+	if err := writeBucketConfig(bucketDir, bucketName, taggingFileName, data); err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to save bucket tagging: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBucketTaggingHandler возвращает теги ведра (GET /{bucket}?tagging)
+func GetBucketTaggingHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(bucketPath, taggingFileName))
+	if os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchTagSet)
+		return
+	} else if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to read bucket tagging: %v", err))
+		return
+	}
+	var tagging Tagging
+	if err := xml.Unmarshal(data, &tagging); err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("malformed bucket tagging: %v", err))
+		return
+	}
+	tagging.XMLName = s3error.XMLName("Tagging")
+	s3error.WriteXML(w, r, http.StatusOK, tagging)
+}
+
+// DeleteBucketTaggingHandler удаляет теги ведра (DELETE /{bucket}?tagging)
+func DeleteBucketTaggingHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	if err := os.Remove(filepath.Join(bucketPath, taggingFileName)); err != nil && !os.IsNotExist(err) {
+		s3error.WriteError(w, r, fmt.Errorf("unable to delete bucket tagging: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateTags проверяет набор тегов по правилам S3 и возвращает его в виде карты
+func validateTags(tags []Tag, limit int) (map[string]string, error) {
+	if len(tags) > limit {
+		return nil, s3error.ErrInvalidTag.WithMessage(fmt.Sprintf("Tags cannot be greater than %d", limit))
+	}
+	result := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		if err := validateTag(tag.Key, tag.Value); err != nil {
+			return nil, err
+		}
+		if _, duplicate := result[tag.Key]; duplicate {
+			return nil, s3error.ErrInvalidTag.WithMessage("Cannot provide multiple Tags with the same key")
+		}
+		result[tag.Key] = tag.Value
+	}
+	return result, nil
+}
+
+// validateTag проверяет длину и допустимые символы ключа и значения тега
+func validateTag(key, value string) error {
+	if key == "" || utf8.RuneCountInString(key) > maxTagKeyLen {
+		return s3error.ErrInvalidTag.WithMessage("The TagKey you have provided is invalid")
+	}
+	if utf8.RuneCountInString(value) > maxTagValueLen {
+		return s3error.ErrInvalidTag.WithMessage("The TagValue you have provided is invalid")
+	}
+	// Префикс aws: зарезервирован за системными тегами
+	if strings.HasPrefix(strings.ToLower(key), "aws:") {
+		return s3error.ErrInvalidTag.WithMessage("Your TagKey cannot be prefixed with aws:")
+	}
+	if !validTagChars(key) {
+		return s3error.ErrInvalidTag.WithMessage("The TagKey you have provided is invalid")
+	}
+	if !validTagChars(value) {
+		return s3error.ErrInvalidTag.WithMessage("The TagValue you have provided is invalid")
+	}
+	return nil
+}
+
+// validTagChars проверяет, что строка состоит из букв, цифр, пробелов и символов + - = . _ : / @
+func validTagChars(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && !unicode.IsSpace(c) && !strings.ContainsRune("+-=._:/@", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTaggingHeader разбирает заголовок x-amz-tagging вида key1=value1&key2=value2
+func parseTaggingHeader(header string) (map[string]string, error) {
+	if header == "" {
+		return nil, nil
+	}
+	values, err := url.ParseQuery(header)
+	if err != nil {
+		return nil, s3error.ErrInvalidArgument.WithMessage("The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+	}
+	tags := make([]Tag, 0, len(values))
+	for key, list := range values {
+		if len(list) > 1 {
+			return nil, s3error.ErrInvalidArgument.WithMessage("The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+		}
+		tags = append(tags, Tag{Key: key, Value: list[0]})
+	}
+	return validateTags(tags, maxObjectTags)
+}
+
+// newTagging строит ответ с тегами, упорядоченными по ключу
+func newTagging(tags map[string]string) Tagging {
+	tagging := Tagging{XMLName: s3error.XMLName("Tagging")}
+	for key, value := range tags {
+		tagging.TagSet.Tags = append(tagging.TagSet.Tags, Tag{Key: key, Value: value})
+	}
+	sort.Slice(tagging.TagSet.Tags, func(i, j int) bool { return tagging.TagSet.Tags[i].Key < tagging.TagSet.Tags[j].Key })
+	return tagging
+}
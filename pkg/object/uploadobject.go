@@ -1,6 +1,9 @@
 package object
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/xml"
 	"fmt"
@@ -10,10 +13,16 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
 	"time"
+	"unicode/utf8"
+
+	"triple-s/pkg/acl"
+	"triple-s/pkg/s3error"
 )
 
+// maxObjectKeyLength — максимальная длина ключа объекта в байтах
+const maxObjectKeyLength = 1024
+
 type ObjectMetadata struct {
 	XMLName      xml.Name `xml:"Object"`
 	Key          string   `xml:"Key"`
@@ -26,109 +35,193 @@ type ObjectMetadata struct {
 func UploadObjectHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey string) {
 	// 1. Проверка имени ведра и ключа объекта
 	if bucketName == "" || objectKey == "" {
-		http.Error(w, "400 Bad Request: Missing bucket name or object key", http.StatusBadRequest)
+		s3error.WriteError(w, r, s3error.ErrInvalidURI.WithMessage("missing bucket name or object key"))
 		return
 	}
 
 	// 2. Проверка существования ведра
 	bucketPath := filepath.Join(bucketDir, bucketName)
 	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
-		http.Error(w, "404 Not Found: Bucket does not exist", http.StatusNotFound)
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
 		return
 	}
 
 	// 3. Валидация ключа объекта
 	if err := validateObjectKey(objectKey); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		s3error.WriteError(w, r, err)
 		return
 	}
 
-	// 4. Сохранение объекта в файловую систему (перезаписывается, если уже существует)
-	objectPath := filepath.Join(bucketPath, objectKey)
-	file, err := os.Create(objectPath)
+	// 4. Сбор пользовательских метаданных, тегов, ACL, шифрования и удержания, проверка Content-MD5 и условной записи If-None-Match: *
+	metadata, err := extractMetadata(r.Header)
 	if err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to create object file", http.StatusInternalServerError)
+		s3error.WriteError(w, r, err)
 		return
 	}
-	defer file.Close()
-
-	// 5. Запись данных объекта
-	if _, err := io.Copy(file, r.Body); err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to write object data", http.StatusInternalServerError)
+	tags, err := parseTaggingHeader(r.Header.Get("x-amz-tagging"))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	objectACL, err := acl.Canned(r.Header.Get("x-amz-acl"), requestOwner(r, bucketDir, bucketName))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	encryption, err := requestEncryption(r, bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	lock, err := requestObjectLock(r, bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	expectedMD5, err := parseContentMD5(r.Header.Get("Content-MD5"))
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	// Существующий ключ отклоняется до чтения тела; окончательная проверка — при сохранении
+	if err := checkCreateOnly(r.Header.Get("If-None-Match"), objectPath(bucketDir, bucketName, objectKey)); err != nil {
+		s3error.WriteError(w, r, err)
 		return
 	}
 
-	// 6. Получаем информацию о объекте
-	objectInfo, err := os.Stat(objectPath)
+	// 5. Запись данных объекта во временный файл с подсчетом MD5 открытых данных;
+	// при шифровании данные шифруются новым ключом данных
+	envelope, key, err := newEnvelope(encryption)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	hash := md5.New()
+	tmpPath, size, err := writeObjectData(bucketDir, r.Body, envelope, key, hash)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	digest := hash.Sum(nil)
+	if expectedMD5 != nil && !bytes.Equal(digest, expectedMD5) {
+		os.Remove(tmpPath)
+		s3error.WriteError(w, r, s3error.ErrBadDigest)
+		return
+	}
+	etag, err := objectETag(envelope, digest)
 	if err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to get object info", http.StatusInternalServerError)
+		os.Remove(tmpPath)
+		s3error.WriteError(w, r, err)
 		return
 	}
 
-	// 7. Обновление метаданных объекта в CSV
-	err = updateObjectMetadata(bucketName, objectKey, objectInfo.Size(), bucketDir, r.Header.Get("Content-Type"))
+	// 6. Сохранение объекта и его метаданных; прежняя версия сохраняется,
+	// если в ведре включено версионирование, иначе перезаписывается
+	record, err := putObject(bucketDir, bucketName, tmpPath, objectRecord{
+		Key:          objectKey,
+		Size:         size,
+		ContentType:  defaultContentType(objectKey, r.Header.Get("Content-Type")),
+		LastModified: time.Now().Format(time.RFC3339),
+		ETag:         etag,
+		Metadata:     metadata,
+		Tags:         tags,
+		ACL:          objectACL,
+		Encryption:   envelope,
+		Lock:         lock,
+	}, r.Header.Get("If-None-Match") == "*")
 	if err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to update object metadata", http.StatusInternalServerError)
+		s3error.WriteError(w, r, err)
 		return
 	}
 
-	// 8. Возвращаем успешный ответ
+	// 7. Возвращаем успешный ответ
 	objectMetadata := ObjectMetadata{
 		Key:          objectKey,
-		Size:         objectInfo.Size(),
-		LastModified: objectInfo.ModTime().Format(time.RFC3339),
+		Size:         record.Size,
+		LastModified: record.LastModified,
 		ContentType:  r.Header.Get("Content-Type"),
 	}
-	w.Header().Set("Content-Type", "application/xml")
-	w.WriteHeader(http.StatusOK)
-	if err := xml.NewEncoder(w).Encode(objectMetadata); err != nil {
-		http.Error(w, "500 Internal Server Error: Unable to encode XML", http.StatusInternalServerError)
+	w.Header().Set("ETag", etag)
+	if record.VersionID != "" {
+		w.Header().Set("x-amz-version-id", record.VersionID)
 	}
+	setEncryptionHeaders(w, r, envelope)
+	s3error.WriteXML(w, r, http.StatusOK, objectMetadata)
 }
 
-// validateObjectKey проверяет, соответствует ли ключ объекта правилам
+// validateObjectKey проверяет, соответствует ли ключ объекта правилам S3:
+// непустая строка UTF-8 длиной не более maxObjectKeyLength байт
 func validateObjectKey(objectKey string) error {
-	keyPattern := `^[a-zA-Z0-9._-]{1,255}$`
-	if matched, _ := regexp.MatchString(keyPattern, objectKey); !matched {
-		return fmt.Errorf("400 Bad Request: Object key must be 1-255 characters long and can only contain letters, numbers, underscores, hyphens, and periods")
+	if len(objectKey) > maxObjectKeyLength {
+		return s3error.ErrKeyTooLong
+	}
+	if objectKey == "" || !utf8.ValidString(objectKey) {
+		return s3error.ErrInvalidArgument.WithMessage("object key must be a non-empty UTF-8 string")
 	}
 	return nil
 }
 
-// updateObjectMetadata обновляет CSV файл с метаданными объектов
-func updateObjectMetadata(bucketName, objectKey string, size int64, dataDir, contentType string) error {
-	objectCSVPath := fmt.Sprintf("%s/%s/objects.csv", dataDir, bucketName)
-
-	// Если Content-Type не был передан, определяем его по расширению файла
-	if contentType == "" {
-		ext := path.Ext(objectKey)
-		contentType = mime.TypeByExtension(ext)
-		if contentType == "" {
-			contentType = "application/octet-stream" // По умолчанию
-		}
+// parseContentMD5 декодирует заголовок Content-MD5; пустой заголовок означает отсутствие проверки
+func parseContentMD5(header string) ([]byte, error) {
+	if header == "" {
+		return nil, nil
 	}
+	digest, err := base64.StdEncoding.DecodeString(header)
+	if err != nil || len(digest) != md5.Size {
+		return nil, s3error.ErrInvalidDigest
+	}
+	return digest, nil
+}
 
-	// Чтение существующих записей из CSV
-	records, err := readCSV(objectCSVPath)
+// readXMLBody читает XML-тело конфигурационного запроса не больше maxSize байт,
+// проверяет Content-MD5, если он передан, и разбирает документ в v
+func readXMLBody(r *http.Request, maxSize int64, v any) error {
+	expectedMD5, err := parseContentMD5(r.Header.Get("Content-MD5"))
 	if err != nil {
 		return err
 	}
-
-	// Проверяем, существует ли запись с таким объектом, и удаляем её
-	var updatedRecords [][]string
-	for _, record := range records {
-		if record[0] != objectKey { // Убираем запись с удаляемым объектом
-			updatedRecords = append(updatedRecords, record)
-		}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		return fmt.Errorf("unable to read request body: %w", err)
+	}
+	if int64(len(body)) > maxSize {
+		return s3error.ErrMalformedXML
+	}
+	if digest := md5.Sum(body); expectedMD5 != nil && !bytes.Equal(digest[:], expectedMD5) {
+		return s3error.ErrBadDigest
 	}
+	if err := xml.Unmarshal(body, v); err != nil {
+		return s3error.ErrMalformedXML
+	}
+	return nil
+}
 
-	// Добавляем новую запись для текущего объекта
-	lastModified := time.Now().Format(time.RFC3339)
-	newRecord := []string{objectKey, fmt.Sprintf("%d", size), contentType, lastModified}
-	updatedRecords = append(updatedRecords, newRecord)
+// checkCreateOnly реализует If-None-Match: * — запись разрешена, только если объекта еще нет
+func checkCreateOnly(ifNoneMatch, objectPath string) error {
+	if ifNoneMatch == "" {
+		return nil
+	}
+	if ifNoneMatch != "*" {
+		return s3error.ErrNotImplemented.WithMessage("only If-None-Match: * is supported for uploads")
+	}
+	if _, err := os.Stat(objectPath); err == nil {
+		return s3error.ErrPreconditionFailed
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("unable to get object info: %v", err)
+	}
+	return nil
+}
 
-	// Перезаписываем CSV файл с обновлёнными записями
-	return writeCSV(objectCSVPath, updatedRecords)
+// defaultContentType определяет Content-Type по расширению файла, если он не был передан
+func defaultContentType(objectKey, contentType string) string {
+	if contentType != "" {
+		return contentType
+	}
+	contentType = mime.TypeByExtension(path.Ext(objectKey))
+	if contentType == "" {
+		contentType = "application/octet-stream" // По умолчанию
+	}
+	return contentType
 }
 
 // readCSV читает все записи из CSV файла
@@ -140,6 +233,7 @@ func readCSV(filePath string) ([][]string, error) {
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil && err != io.EOF {
 		return nil, fmt.Errorf("unable to read objects metadata: %v", err)
@@ -148,23 +242,15 @@ func readCSV(filePath string) ([][]string, error) {
 	return records, nil
 }
 
-// writeCSV перезаписывает CSV файл новыми записями
+// writeCSV атомарно заменяет CSV файл новыми записями
 func writeCSV(filePath string, records [][]string) error {
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0o644)
-	if err != nil {
-		return fmt.Errorf("unable to open objects metadata file for writing: %v", err)
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("unable to encode %s: %v", filepath.Base(filePath), err)
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Запись всех строк в файл
-	for _, record := range records {
-		if err := writer.Write(record); err != nil {
-			return fmt.Errorf("unable to write object metadata: %v", err)
-		}
+	if err := writeFileAtomic(filePath, buf.Bytes()); err != nil {
+		return fmt.Errorf("unable to write %s: %v", filepath.Base(filePath), err)
 	}
-
 	return nil
 }
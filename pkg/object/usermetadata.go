@@ -0,0 +1,92 @@
+package object
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"triple-s/pkg/s3error"
+)
+
+// maxUserMetadataSize — предельный размер пользовательских метаданных x-amz-meta-* (как в S3)
+const maxUserMetadataSize = 2 << 10
+
+// userMetadataPrefix — префикс заголовков пользовательских метаданных
+const userMetadataPrefix = "x-amz-meta-"
+
+// websiteRedirectHeader — адрес, на который сайт ведра перенаправляет запрос объекта (301)
+const websiteRedirectHeader = "X-Amz-Website-Redirect-Location"
+
+// maxWebsiteRedirectLength — предельная длина x-amz-website-redirect-location (как в S3)
+const maxWebsiteRedirectLength = 2 << 10
+
+// storedHeaders — стандартные заголовки, которые сохраняются при загрузке и возвращаются при чтении
+var storedHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Expires",
+	websiteRedirectHeader,
+}
+
+// extractMetadata собирает x-amz-meta-* и стандартные заголовки из запроса загрузки
+func extractMetadata(h http.Header) (map[string]string, error) {
+	metadata := make(map[string]string)
+	userSize := 0
+	for name, values := range h {
+		lower := strings.ToLower(name)
+		if !strings.HasPrefix(lower, userMetadataPrefix) {
+			continue
+		}
+		value := strings.Join(values, ",")
+		metadata[lower] = value
+		userSize += len(lower) - len(userMetadataPrefix) + len(value)
+	}
+	if userSize > maxUserMetadataSize {
+		return nil, s3error.ErrMetadataTooLarge
+	}
+
+	for _, name := range storedHeaders {
+		if value := h.Get(name); value != "" {
+			metadata[name] = value
+		}
+	}
+
+	// Перенаправление допускается внутри сайта ("/путь") или на абсолютный адрес
+	if location, ok := metadata[websiteRedirectHeader]; ok {
+		if len(location) > maxWebsiteRedirectLength || !(strings.HasPrefix(location, "/") || strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")) {
+			return nil, s3error.ErrInvalidArgument.WithMessage("The website redirect location must have a prefix of 'http://' or 'https://' or '/'")
+		}
+	}
+	return metadata, nil
+}
+
+// encodeMetadata сериализует метаданные в строку вида key=value&... для хранения в CSV
+func encodeMetadata(metadata map[string]string) string {
+	values := url.Values{}
+	for name, value := range metadata {
+		values.Set(name, value)
+	}
+	return values.Encode()
+}
+
+// decodeMetadata разбирает строку, созданную encodeMetadata
+func decodeMetadata(encoded string) (map[string]string, error) {
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(map[string]string, len(values))
+	for name := range values {
+		metadata[name] = values.Get(name)
+	}
+	return metadata, nil
+}
+
+// setMetadataHeaders возвращает сохраненные метаданные в заголовках ответа
+func setMetadataHeaders(w http.ResponseWriter, metadata map[string]string) {
+	for name, value := range metadata {
+		w.Header().Set(name, value)
+	}
+}
@@ -0,0 +1,222 @@
+package object
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"triple-s/pkg/s3error"
+)
+
+// ListVersionsResult — ответ ListObjectVersions
+type ListVersionsResult struct {
+	XMLName             xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListVersionsResult"`
+	Name                string   `xml:"Name"`
+	Prefix              string   `xml:"Prefix"`
+	KeyMarker           string   `xml:"KeyMarker"`
+	VersionIDMarker     string   `xml:"VersionIdMarker"`
+	NextKeyMarker       string   `xml:"NextKeyMarker,omitempty"`
+	NextVersionIDMarker string   `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int      `xml:"MaxKeys"`
+	IsTruncated         bool     `xml:"IsTruncated"`
+	// Entries содержит ObjectVersion и DeleteMarkerEntry в порядке ключей, от новых версий к старым
+	Entries []any
+}
+
+// ObjectVersion — версия объекта в ответе ListObjectVersions
+type ObjectVersion struct {
+	XMLName      xml.Name `xml:"Version"`
+	Key          string   `xml:"Key"`
+	VersionID    string   `xml:"VersionId"`
+	IsLatest     bool     `xml:"IsLatest"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+	Size         int64    `xml:"Size"`
+	StorageClass string   `xml:"StorageClass"`
+}
+
+// DeleteMarkerEntry — маркер удаления в ответе ListObjectVersions
+type DeleteMarkerEntry struct {
+	XMLName      xml.Name `xml:"DeleteMarker"`
+	Key          string   `xml:"Key"`
+	VersionID    string   `xml:"VersionId"`
+	IsLatest     bool     `xml:"IsLatest"`
+	LastModified string   `xml:"LastModified"`
+}
+
+// PutBucketVersioningHandler включает или приостанавливает версионирование (PUT /{bucket}?versioning)
+func PutBucketVersioningHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	var config VersioningConfiguration
+	if err := readXMLBody(r, maxVersioningBodySize, &config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	// Вернуть ведро в неверсионируемое состояние нельзя — только приостановить версионирование
+	if config.Status != versioningEnabled && config.Status != versioningSuspended {
+		s3error.WriteError(w, r, s3error.ErrIllegalVersioning)
+		return
+	}
+	if config.MFADelete == "Enabled" {
+		s3error.WriteError(w, r, s3error.ErrNotImplemented.WithMessage("MFA delete is not supported"))
+		return
+	}
+
+	data, err := xml.Marshal(VersioningConfiguration{Status: config.Status})
+	if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to encode versioning configuration: %v", err))
+		return
+	}
+
+	// Запись под metadataMu, чтобы параллельные PUT и DELETE видели одно состояние.
+	// Версионирование ведра с Object Lock приостановить нельзя.
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+	_, locked, err := loadObjectLock(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if locked && config.Status != versioningEnabled {
+		s3error.WriteError(w, r, s3error.ErrInvalidBucketState.WithMessage("An Object Lock configuration is present on this bucket, so the versioning state cannot be changed."))
+		return
+	}
+	if err := writeBucketConfig(bucketDir, bucketName, versioningFileName, data); err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to save versioning configuration: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketVersioningHandler возвращает состояние версионирования (GET /{bucket}?versioning)
+func GetBucketVersioningHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	status, err := loadVersioning(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	s3error.WriteXML(w, r, http.StatusOK, VersioningConfiguration{
+		XMLName: s3error.XMLName("VersioningConfiguration"),
+		Status:  status,
+	})
+}
+
+// ListObjectVersionsHandler перечисляет все версии и маркеры удаления (GET /{bucket}?versions)
+func ListObjectVersionsHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	keyMarker := query.Get("key-marker")
+	versionIDMarker := query.Get("version-id-marker")
+	maxKeys, err := queryInt(query.Get("max-keys"), defaultMaxKeys)
+	if err != nil {
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("max-keys must be a non-negative integer"))
+		return
+	}
+	if versionIDMarker != "" && keyMarker == "" {
+		s3error.WriteError(w, r, s3error.ErrInvalidArgument.WithMessage("a version-id marker cannot be specified without a key marker"))
+		return
+	}
+
+	// 1. Текущие версии и предыдущие (от новых к старым), упорядоченные по ключу
+	current, err := loadObjectRecords(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	previous, err := loadVersionRecords(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	versions := make([]objectRecord, 0, len(current)+len(previous))
+	versions = append(versions, current...)
+	for i := len(previous) - 1; i >= 0; i-- {
+		versions = append(versions, previous[i])
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].Key < versions[j].Key })
+
+	// 2. Пропуск до маркера и отбор по префиксу
+	result := ListVersionsResult{
+		Name:            bucketName,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIDMarker: versionIDMarker,
+		MaxKeys:         maxKeys,
+	}
+	skipping := versionIDMarker != ""
+	lastKey, count := "", 0
+	for _, version := range versions {
+		isLatest := version.Key != lastKey
+		lastKey = version.Key
+
+		if version.Key < keyMarker {
+			continue
+		}
+		// Без маркера версии ключ-маркер пропускается целиком, иначе — до маркера версии включительно
+		if version.Key == keyMarker && (versionIDMarker == "" || skipping) {
+			if versionOf(version) == versionIDMarker {
+				skipping = false
+			}
+			continue
+		}
+		if !strings.HasPrefix(version.Key, prefix) {
+			continue
+		}
+
+		if count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		count++
+		result.NextKeyMarker, result.NextVersionIDMarker = version.Key, versionOf(version)
+		if version.DeleteMarker {
+			result.Entries = append(result.Entries, DeleteMarkerEntry{
+				Key:          version.Key,
+				VersionID:    versionOf(version),
+				IsLatest:     isLatest,
+				LastModified: version.LastModified,
+			})
+			continue
+		}
+		result.Entries = append(result.Entries, ObjectVersion{
+			Key:          version.Key,
+			VersionID:    versionOf(version),
+			IsLatest:     isLatest,
+			LastModified: version.LastModified,
+			ETag:         version.ETag,
+			Size:         version.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIDMarker = "", ""
+	}
+
+	s3error.WriteXML(w, r, http.StatusOK, result)
+}
+
+// setDeleteMarkerHeaders сообщает клиенту, что запрошенная версия — маркер удаления
+func setDeleteMarkerHeaders(w http.ResponseWriter, record objectRecord) {
+	if record.DeleteMarker {
+		w.Header().Set("x-amz-delete-marker", "true")
+		w.Header().Set("x-amz-version-id", versionOf(record))
+	}
+}
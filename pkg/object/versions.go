@@ -0,0 +1,544 @@
+package object
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"triple-s/pkg/s3error"
+)
+
+// Хранение версий объектов.
+// Текущая версия ключа, как и в неверсионируемом ведре, описана в objects.csv и лежит в objectPath.
+// Предыдущие версии и маркеры удаления дописываются в versions.csv в порядке появления,
+// а данные предыдущих версий хранятся в файлах versionPath.
+const (
+	versioningFileName = "versioning.xml"
+	versionsFileName   = "versions.csv"
+
+	// maxVersioningBodySize ограничивает размер тела PutBucketVersioning
+	maxVersioningBodySize = 16 << 10
+
+	// nullVersionID — идентификатор версии, создаваемой при приостановленном версионировании
+	nullVersionID = "null"
+
+	versioningEnabled   = "Enabled"
+	versioningSuspended = "Suspended"
+)
+
+// VersioningConfiguration — тело PutBucketVersioning и ответ GetBucketVersioning
+type VersioningConfiguration struct {
+	XMLName   xml.Name
+	Status    string `xml:"Status,omitempty"`
+	MFADelete string `xml:"MfaDelete,omitempty"`
+}
+
+// loadVersioning возвращает состояние версионирования ведра: "", Enabled или Suspended
+func loadVersioning(bucketDir, bucketName string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(bucketDir, bucketName, versioningFileName))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to read versioning configuration: %v", err)
+	}
+	var config VersioningConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("malformed versioning configuration: %v", err)
+	}
+	return config.Status, nil
+}
+
+// versionPath возвращает путь к данным предыдущей версии объекта
+func versionPath(bucketDir, bucketName, objectKey, versionID string) string {
+	sum := sha256.Sum256([]byte(objectKey + "\x00" + versionID))
+	return filepath.Join(bucketDir, bucketName, hex.EncodeToString(sum[:]))
+}
+
+// versionOf возвращает идентификатор версии записи; объекты без версии считаются версией null
+func versionOf(record objectRecord) string {
+	if record.VersionID == "" {
+		return nullVersionID
+	}
+	return record.VersionID
+}
+
+// newVersionID генерирует случайный идентификатор версии
+func newVersionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("unable to generate version id: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// loadVersionRecords читает предыдущие версии и маркеры удаления из versions.csv
+func loadVersionRecords(bucketDir, bucketName string) ([]objectRecord, error) {
+	versionsPath := filepath.Join(bucketDir, bucketName, versionsFileName)
+	if _, err := os.Stat(versionsPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	records, err := readCSV(versionsPath)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]objectRecord, 0, len(records))
+	for _, record := range records {
+		version, err := parseObjectRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// lookupVersion находит версию объекта и путь к ее данным; пустой versionID означает текущую версию.
+// Если ключ удален маркером, возвращается запись маркера вместе с ошибкой:
+// NoSuchKey для текущей версии и MethodNotAllowed для явно запрошенного маркера.
+func lookupVersion(bucketDir, bucketName, objectKey, versionID string) (objectRecord, string, error) {
+	if versionID == "" {
+		record, err := lookupObject(bucketDir, bucketName, objectKey)
+		if err == nil {
+			return record, objectPath(bucketDir, bucketName, objectKey), nil
+		}
+		if !errors.Is(err, s3error.ErrNoSuchKey) {
+			return objectRecord{}, "", err
+		}
+		versions, loadErr := loadVersionRecords(bucketDir, bucketName)
+		if loadErr != nil {
+			return objectRecord{}, "", loadErr
+		}
+		if latest, found := latestVersion(versions, objectKey); found && latest.DeleteMarker {
+			return latest, "", err
+		}
+		return objectRecord{}, "", err
+	}
+
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		return objectRecord{}, "", s3error.ErrNoSuchBucket
+	}
+	current, found, err := findObjectRecord(bucketDir, bucketName, objectKey)
+	if err != nil {
+		return objectRecord{}, "", err
+	}
+	if found && versionOf(current) == versionID {
+		return current, objectPath(bucketDir, bucketName, objectKey), nil
+	}
+
+	versions, err := loadVersionRecords(bucketDir, bucketName)
+	if err != nil {
+		return objectRecord{}, "", err
+	}
+	for _, version := range versions {
+		if version.Key != objectKey || versionOf(version) != versionID {
+			continue
+		}
+		if version.DeleteMarker {
+			return version, "", s3error.ErrMethodNotAllowed
+		}
+		return version, versionPath(bucketDir, bucketName, objectKey, versionID), nil
+	}
+	return objectRecord{}, "", s3error.ErrNoSuchVersion
+}
+
+// openVersion находит версию объекта и открывает ее данные под блокировкой метаданных.
+// Открытый файл остается читаемым, даже если параллельная запись заменит или переместит его,
+// поэтому метаданные и данные всегда относятся к одной версии. Файл закрывает вызывающий.
+func openVersion(bucketDir, bucketName, objectKey, versionID string) (objectRecord, *os.File, error) {
+	metadataMu.RLock()
+	defer metadataMu.RUnlock()
+
+	record, dataPath, err := lookupVersion(bucketDir, bucketName, objectKey, versionID)
+	if err != nil {
+		return record, nil, err
+	}
+	file, err := os.Open(dataPath)
+	if os.IsNotExist(err) {
+		return objectRecord{}, nil, s3error.ErrNoSuchKey
+	} else if err != nil {
+		return objectRecord{}, nil, fmt.Errorf("unable to open object: %v", err)
+	}
+	return record, file, nil
+}
+
+// latestVersion возвращает самую новую из предыдущих версий ключа
+func latestVersion(versions []objectRecord, objectKey string) (objectRecord, bool) {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Key == objectKey {
+			return versions[i], true
+		}
+	}
+	return objectRecord{}, false
+}
+
+// bucketIndex — записи objects.csv и versions.csv ведра, загруженные для изменения.
+// Методы сразу перемещают файлы данных, а метаданные записываются одним вызовом save.
+// Вызывающий держит metadataMu от загрузки до сохранения.
+type bucketIndex struct {
+	bucketDir  string
+	bucketName string
+	versioning string
+	current    []objectRecord
+	versions   []objectRecord
+	// versionsChanged — нужно ли перезаписать versions.csv
+	versionsChanged bool
+}
+
+// deleteOutcome описывает результат удаления для заголовков и ответа DeleteObjects
+type deleteOutcome struct {
+	VersionID    string
+	DeleteMarker bool
+}
+
+// loadBucketIndex читает метаданные ведра и состояние версионирования
+func loadBucketIndex(bucketDir, bucketName string) (*bucketIndex, error) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		return nil, s3error.ErrNoSuchBucket
+	}
+	versioning, err := loadVersioning(bucketDir, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	current, err := loadObjectRecords(bucketDir, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := loadVersionRecords(bucketDir, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	return &bucketIndex{
+		bucketDir:  bucketDir,
+		bucketName: bucketName,
+		versioning: versioning,
+		current:    current,
+		versions:   versions,
+	}, nil
+}
+
+// save записывает измененные метаданные ведра
+func (idx *bucketIndex) save() error {
+	records := make([][]string, 0, len(idx.current))
+	for _, record := range idx.current {
+		records = append(records, formatObjectRecord(record))
+	}
+	if err := writeCSV(filepath.Join(idx.bucketDir, idx.bucketName, "objects.csv"), records); err != nil {
+		return err
+	}
+	if !idx.versionsChanged {
+		return nil
+	}
+	records = make([][]string, 0, len(idx.versions))
+	for _, record := range idx.versions {
+		records = append(records, formatObjectRecord(record))
+	}
+	return writeCSV(filepath.Join(idx.bucketDir, idx.bucketName, versionsFileName), records)
+}
+
+// findCurrent возвращает позицию текущей версии ключа или -1
+func (idx *bucketIndex) findCurrent(objectKey string) int {
+	for i, record := range idx.current {
+		if record.Key == objectKey {
+			return i
+		}
+	}
+	return -1
+}
+
+// findVersion возвращает позицию предыдущей версии ключа или -1
+func (idx *bucketIndex) findVersion(objectKey, versionID string) int {
+	for i, record := range idx.versions {
+		if record.Key == objectKey && versionOf(record) == versionID {
+			return i
+		}
+	}
+	return -1
+}
+
+// put делает временный файл текущей версией ключа.
+// При включенном версионировании прежняя текущая версия сохраняется как предыдущая,
+// при приостановленном — новая версия получает идентификатор null и заменяет прежнюю версию null.
+// Заменить версию под удержанием Object Lock нельзя.
+func (idx *bucketIndex) put(tmpPath string, record objectRecord) (objectRecord, error) {
+	if idx.versioning == "" {
+		if err := idx.checkRemoval(record.Key, nullVersionID, false); err != nil {
+			os.Remove(tmpPath)
+			return objectRecord{}, err
+		}
+	}
+	record.VersionID = ""
+	switch idx.versioning {
+	case versioningEnabled:
+		versionID, err := newVersionID()
+		if err != nil {
+			os.Remove(tmpPath)
+			return objectRecord{}, err
+		}
+		record.VersionID = versionID
+	case versioningSuspended:
+		record.VersionID = nullVersionID
+	}
+
+	if idx.versioning != "" {
+		if err := idx.archiveCurrent(record.Key); err != nil {
+			os.Remove(tmpPath)
+			return objectRecord{}, err
+		}
+	}
+	if err := commitTempObject(tmpPath, objectPath(idx.bucketDir, idx.bucketName, record.Key)); err != nil {
+		return objectRecord{}, err
+	}
+
+	if i := idx.findCurrent(record.Key); i >= 0 {
+		idx.current[i] = record
+	} else {
+		idx.current = append(idx.current, record)
+	}
+	return record, nil
+}
+
+// deleteCurrent удаляет ключ без указания версии. В неверсионируемом ведре объект удаляется
+// (для отсутствующего ключа возвращается NoSuchKey), иначе создается маркер удаления.
+func (idx *bucketIndex) deleteCurrent(objectKey string) (deleteOutcome, error) {
+	if idx.versioning == "" {
+		i := idx.findCurrent(objectKey)
+		if i < 0 {
+			return deleteOutcome{}, s3error.ErrNoSuchKey
+		}
+		if err := idx.current[i].Lock.checkRemoval(time.Now(), false); err != nil {
+			return deleteOutcome{}, err
+		}
+		if err := removeDataFile(objectPath(idx.bucketDir, idx.bucketName, objectKey)); err != nil {
+			return deleteOutcome{}, err
+		}
+		idx.current = append(idx.current[:i], idx.current[i+1:]...)
+		return deleteOutcome{}, nil
+	}
+
+	if err := idx.archiveCurrent(objectKey); err != nil {
+		return deleteOutcome{}, err
+	}
+	marker := objectRecord{
+		Key:          objectKey,
+		LastModified: time.Now().Format(time.RFC3339),
+		VersionID:    nullVersionID,
+		DeleteMarker: true,
+	}
+	if idx.versioning == versioningEnabled {
+		versionID, err := newVersionID()
+		if err != nil {
+			return deleteOutcome{}, err
+		}
+		marker.VersionID = versionID
+	}
+	idx.versions = append(idx.versions, marker)
+	idx.versionsChanged = true
+	return deleteOutcome{VersionID: marker.VersionID, DeleteMarker: true}, nil
+}
+
+// deleteVersion безвозвратно удаляет указанную версию ключа или маркер удаления.
+// Если удалена текущая версия, текущей становится самая новая из предыдущих.
+// Отсутствующая версия не считается ошибкой. Версия под удержанием Object Lock не удаляется;
+// bypassGovernance разрешает удалить версию в режиме GOVERNANCE.
+func (idx *bucketIndex) deleteVersion(objectKey, versionID string, bypassGovernance bool) (deleteOutcome, error) {
+	if err := idx.checkRemoval(objectKey, versionID, bypassGovernance); err != nil {
+		return deleteOutcome{}, err
+	}
+	outcome := deleteOutcome{VersionID: versionID}
+	if i := idx.findCurrent(objectKey); i >= 0 && versionOf(idx.current[i]) == versionID {
+		if err := removeDataFile(objectPath(idx.bucketDir, idx.bucketName, objectKey)); err != nil {
+			return deleteOutcome{}, err
+		}
+		idx.current = append(idx.current[:i], idx.current[i+1:]...)
+	} else if j := idx.findVersion(objectKey, versionID); j >= 0 {
+		outcome.DeleteMarker = idx.versions[j].DeleteMarker
+		if !outcome.DeleteMarker {
+			if err := removeDataFile(versionPath(idx.bucketDir, idx.bucketName, objectKey, versionID)); err != nil {
+				return deleteOutcome{}, err
+			}
+		}
+		idx.versions = append(idx.versions[:j], idx.versions[j+1:]...)
+		idx.versionsChanged = true
+	} else {
+		return outcome, nil
+	}
+
+	return outcome, idx.promote(objectKey)
+}
+
+// archiveCurrent переводит текущую версию ключа в предыдущие.
+// При приостановленном версионировании прежняя версия null удаляется, если она не под удержанием.
+func (idx *bucketIndex) archiveCurrent(objectKey string) error {
+	if idx.versioning == versioningSuspended {
+		if err := idx.checkRemoval(objectKey, nullVersionID, false); err != nil {
+			return err
+		}
+	}
+	if i := idx.findCurrent(objectKey); i >= 0 {
+		record := idx.current[i]
+		record.VersionID = versionOf(record)
+		err := os.Rename(objectPath(idx.bucketDir, idx.bucketName, objectKey), versionPath(idx.bucketDir, idx.bucketName, objectKey, record.VersionID))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to keep previous version: %v", err)
+		}
+		idx.current = append(idx.current[:i], idx.current[i+1:]...)
+		idx.versions = append(idx.versions, record)
+		idx.versionsChanged = true
+	}
+
+	if idx.versioning == versioningSuspended {
+		if j := idx.findVersion(objectKey, nullVersionID); j >= 0 {
+			if !idx.versions[j].DeleteMarker {
+				if err := removeDataFile(versionPath(idx.bucketDir, idx.bucketName, objectKey, nullVersionID)); err != nil {
+					return err
+				}
+			}
+			idx.versions = append(idx.versions[:j], idx.versions[j+1:]...)
+		}
+	}
+	return nil
+}
+
+// checkRemoval проверяет, что версию ключа можно удалить или заменить: ее не защищает Object Lock
+func (idx *bucketIndex) checkRemoval(objectKey, versionID string, bypassGovernance bool) error {
+	if i := idx.findCurrent(objectKey); i >= 0 && versionOf(idx.current[i]) == versionID {
+		return idx.current[i].Lock.checkRemoval(time.Now(), bypassGovernance)
+	}
+	if j := idx.findVersion(objectKey, versionID); j >= 0 {
+		return idx.versions[j].Lock.checkRemoval(time.Now(), bypassGovernance)
+	}
+	return nil
+}
+
+// promote делает самую новую предыдущую версию текущей, если текущей версии нет
+// и последней записью ключа не является маркер удаления
+func (idx *bucketIndex) promote(objectKey string) error {
+	if idx.findCurrent(objectKey) >= 0 {
+		return nil
+	}
+	latest, found := latestVersion(idx.versions, objectKey)
+	if !found || latest.DeleteMarker {
+		return nil
+	}
+
+	versionID := versionOf(latest)
+	err := os.Rename(versionPath(idx.bucketDir, idx.bucketName, objectKey, versionID), objectPath(idx.bucketDir, idx.bucketName, objectKey))
+	if err != nil {
+		return fmt.Errorf("unable to restore previous version: %v", err)
+	}
+	j := idx.findVersion(objectKey, versionID)
+	idx.versions = append(idx.versions[:j], idx.versions[j+1:]...)
+	idx.versionsChanged = true
+	idx.current = append(idx.current, latest)
+	return nil
+}
+
+// removeDataFile удаляет файл данных, не считая ошибкой его отсутствие
+func removeDataFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to delete object: %v", err)
+	}
+	return nil
+}
+
+// putObject сохраняет временный файл как новую версию объекта. createOnly (If-None-Match: *)
+// запрещает запись, если у ключа есть текущая версия; проверка выполняется под metadataMu,
+// поэтому из двух параллельных записей пройдет только одна.
+func putObject(bucketDir, bucketName, tmpPath string, record objectRecord, createOnly bool) (objectRecord, error) {
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+
+	idx, err := loadBucketIndex(bucketDir, bucketName)
+	if err != nil {
+		os.Remove(tmpPath)
+		return objectRecord{}, err
+	}
+	if createOnly && idx.findCurrent(record.Key) >= 0 {
+		os.Remove(tmpPath)
+		return objectRecord{}, s3error.ErrPreconditionFailed
+	}
+	record, err = idx.put(tmpPath, record)
+	if err != nil {
+		return objectRecord{}, err
+	}
+	if err := idx.save(); err != nil {
+		return objectRecord{}, fmt.Errorf("unable to update object metadata: %w", err)
+	}
+	return record, nil
+}
+
+// removeObject удаляет объект или его версию; пустой versionID означает удаление без указания версии
+func removeObject(bucketDir, bucketName, objectKey, versionID string, bypassGovernance bool) (deleteOutcome, error) {
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+
+	idx, err := loadBucketIndex(bucketDir, bucketName)
+	if err != nil {
+		return deleteOutcome{}, err
+	}
+	var outcome deleteOutcome
+	if versionID == "" {
+		outcome, err = idx.deleteCurrent(objectKey)
+	} else {
+		outcome, err = idx.deleteVersion(objectKey, versionID, bypassGovernance)
+	}
+	if err != nil {
+		return deleteOutcome{}, err
+	}
+	if err := idx.save(); err != nil {
+		return deleteOutcome{}, fmt.Errorf("unable to update object metadata: %w", err)
+	}
+	return outcome, nil
+}
+
+// updateObjectVersion изменяет метаданные версии объекта (теги, ACL, удержание); пустой versionID
+// означает текущую версию. Как и lookupVersion, для маркера удаления возвращает его запись вместе
+// с ошибкой. Ошибка update отменяет изменение.
+func updateObjectVersion(bucketDir, bucketName, objectKey, versionID string, update func(record *objectRecord) error) (objectRecord, error) {
+	metadataMu.Lock()
+	defer metadataMu.Unlock()
+
+	idx, err := loadBucketIndex(bucketDir, bucketName)
+	if err != nil {
+		return objectRecord{}, err
+	}
+	record, err := idx.find(objectKey, versionID)
+	if err != nil {
+		return *record, err
+	}
+	if err := update(record); err != nil {
+		return *record, err
+	}
+	if err := idx.save(); err != nil {
+		return objectRecord{}, fmt.Errorf("unable to update object metadata: %w", err)
+	}
+	return *record, nil
+}
+
+// find возвращает изменяемую запись версии ключа; пустой versionID означает текущую версию
+func (idx *bucketIndex) find(objectKey, versionID string) (*objectRecord, error) {
+	if i := idx.findCurrent(objectKey); i >= 0 && (versionID == "" || versionOf(idx.current[i]) == versionID) {
+		return &idx.current[i], nil
+	}
+	if versionID == "" {
+		if latest, found := latestVersion(idx.versions, objectKey); found && latest.DeleteMarker {
+			return &latest, s3error.ErrNoSuchKey
+		}
+		return &objectRecord{}, s3error.ErrNoSuchKey
+	}
+	j := idx.findVersion(objectKey, versionID)
+	if j < 0 {
+		return &objectRecord{}, s3error.ErrNoSuchVersion
+	}
+	if idx.versions[j].DeleteMarker {
+		return &idx.versions[j], s3error.ErrMethodNotAllowed
+	}
+	idx.versionsChanged = true
+	return &idx.versions[j], nil
+}
@@ -0,0 +1,375 @@
+package object
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"triple-s/pkg/s3error"
+)
+
+const (
+	websiteFileName = "website.xml"
+	// maxWebsiteBodySize ограничивает размер тела PutBucketWebsite
+	maxWebsiteBodySize = 64 << 10
+	// maxRoutingRules — максимальное число правил перенаправления (как в S3)
+	maxRoutingRules = 50
+)
+
+// WebsiteConfiguration — тело PutBucketWebsite и ответ GetBucketWebsite
+type WebsiteConfiguration struct {
+	XMLName               xml.Name
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RoutingRules          []RoutingRule          `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+// RedirectAllRequestsTo перенаправляет все запросы к сайту на другой хост
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+// IndexDocument — суффикс, который дописывается к запросам каталогов ("docs/" -> "docs/index.html")
+type IndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+// ErrorDocument — объект, который отдается вместо страницы ошибки 4xx
+type ErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+// RoutingRule — правило перенаправления; без Condition применяется ко всем запросам
+type RoutingRule struct {
+	Condition *RoutingCondition `xml:"Condition,omitempty"`
+	Redirect  RoutingRedirect   `xml:"Redirect"`
+}
+
+// RoutingCondition — префикс ключа и/или код ошибки, при которых срабатывает правило
+type RoutingCondition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+	HTTPErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+// RoutingRedirect — адрес перенаправления. Пустая строка в ReplaceKeyPrefixWith
+// отличается от отсутствующего элемента: она удаляет префикс из ключа.
+type RoutingRedirect struct {
+	Protocol             string  `xml:"Protocol,omitempty"`
+	HostName             string  `xml:"HostName,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith"`
+	ReplaceKeyWith       *string `xml:"ReplaceKeyWith"`
+	HTTPRedirectCode     string  `xml:"HttpRedirectCode,omitempty"`
+}
+
+// PutBucketWebsiteHandler сохраняет настройки сайта ведра (PUT /{bucket}?website)
+func PutBucketWebsiteHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	var config WebsiteConfiguration
+	if err := readXMLBody(r, maxWebsiteBodySize, &config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if err := validateWebsite(config); err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+
+	config.XMLName = xml.Name{Local: "WebsiteConfiguration"}
+	data, err := xml.Marshal(config)
+	if err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to encode website configuration: %v", err))
+		return
+	}
+	if err := writeBucketConfig(bucketDir, bucketName, websiteFileName, data); err != nil {
+		s3error.WriteError(w, r, fmt.Errorf("unable to save website configuration: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetBucketWebsiteHandler возвращает настройки сайта ведра (GET /{bucket}?website)
+func GetBucketWebsiteHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	config, found, err := loadWebsite(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteError(w, r, err)
+		return
+	}
+	if !found {
+		s3error.WriteError(w, r, s3error.ErrNoSuchWebsiteConfig)
+		return
+	}
+	config.XMLName = s3error.XMLName("WebsiteConfiguration")
+	s3error.WriteXML(w, r, http.StatusOK, config)
+}
+
+// DeleteBucketWebsiteHandler отключает сайт ведра (DELETE /{bucket}?website)
+func DeleteBucketWebsiteHandler(w http.ResponseWriter, r *http.Request, bucketDir, bucketName string) {
+	bucketPath := filepath.Join(bucketDir, bucketName)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		s3error.WriteError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+
+	if err := os.Remove(filepath.Join(bucketPath, websiteFileName)); err != nil && !os.IsNotExist(err) {
+		s3error.WriteError(w, r, fmt.Errorf("unable to delete website configuration: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateWebsite проверяет настройки сайта: либо RedirectAllRequestsTo, либо IndexDocument
+// с необязательными ErrorDocument и правилами перенаправления
+func validateWebsite(config WebsiteConfiguration) error {
+	if redirect := config.RedirectAllRequestsTo; redirect != nil {
+		if config.IndexDocument != nil || config.ErrorDocument != nil || len(config.RoutingRules) > 0 {
+			return s3error.ErrInvalidArgument.WithMessage("RedirectAllRequestsTo cannot be provided in conjunction with other Routing/Redirect configurations.")
+		}
+		if redirect.HostName == "" {
+			return s3error.ErrMalformedXML
+		}
+		return validateProtocol(redirect.Protocol)
+	}
+
+	if config.IndexDocument == nil {
+		return s3error.ErrInvalidArgument.WithMessage("A value for IndexDocument Suffix must be provided if RedirectAllRequestsTo is empty")
+	}
+	if suffix := config.IndexDocument.Suffix; suffix == "" || strings.Contains(suffix, "/") {
+		return s3error.ErrInvalidArgument.WithMessage("The IndexDocument Suffix is not well formed")
+	}
+	if config.ErrorDocument != nil {
+		if err := validateObjectKey(config.ErrorDocument.Key); err != nil {
+			return s3error.ErrInvalidArgument.WithMessage("The ErrorDocument Key is not well formed")
+		}
+	}
+
+	if len(config.RoutingRules) > maxRoutingRules {
+		return s3error.ErrInvalidArgument.WithMessage(fmt.Sprintf("The number of routing rules must not exceed %d", maxRoutingRules))
+	}
+	for _, rule := range config.RoutingRules {
+		redirect := rule.Redirect
+		if redirect.ReplaceKeyPrefixWith != nil && redirect.ReplaceKeyWith != nil {
+			return s3error.ErrInvalidArgument.WithMessage("You can only define ReplaceKeyPrefix or ReplaceKey but not both.")
+		}
+		if redirect == (RoutingRedirect{}) {
+			return s3error.ErrInvalidArgument.WithMessage("A Redirect must specify at least one of Protocol, HostName, ReplaceKeyPrefixWith, ReplaceKeyWith or HttpRedirectCode")
+		}
+		if err := validateProtocol(redirect.Protocol); err != nil {
+			return err
+		}
+		if code := redirect.HTTPRedirectCode; code != "" {
+			if status, err := strconv.Atoi(code); err != nil || status < 301 || status > 399 {
+				return s3error.ErrInvalidArgument.WithMessage("The provided HTTP redirect code (" + code + ") is not valid. Valid codes are 3XX except 300.")
+			}
+		}
+		if rule.Condition != nil {
+			if code := rule.Condition.HTTPErrorCodeReturnedEquals; code != "" {
+				if status, err := strconv.Atoi(code); err != nil || status < 400 || status > 599 {
+					return s3error.ErrInvalidArgument.WithMessage("The provided HTTP error code (" + code + ") is not valid. Valid codes are 4XX or 5XX.")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateProtocol проверяет протокол перенаправления
+func validateProtocol(protocol string) error {
+	if protocol != "" && protocol != "http" && protocol != "https" {
+		return s3error.ErrInvalidArgument.WithMessage("Invalid protocol, protocol can be http or https. If not defined the protocol will be selected automatically.")
+	}
+	return nil
+}
+
+// loadWebsite читает настройки сайта ведра
+func loadWebsite(bucketDir, bucketName string) (WebsiteConfiguration, bool, error) {
+	data, err := os.ReadFile(filepath.Join(bucketDir, bucketName, websiteFileName))
+	if os.IsNotExist(err) {
+		return WebsiteConfiguration{}, false, nil
+	} else if err != nil {
+		return WebsiteConfiguration{}, false, fmt.Errorf("unable to read website configuration: %v", err)
+	}
+	var config WebsiteConfiguration
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return WebsiteConfiguration{}, false, fmt.Errorf("malformed website configuration: %v", err)
+	}
+	return config, true, nil
+}
+
+// ServeWebsite отдает запрос к сайту ведра (GET и HEAD). objectKey — путь запроса без ведра,
+// base — путь, под которым сайт виден клиенту ("/" или "/{bucket}/"); от него строятся
+// перенаправления внутри сайта. authorize проверяет право анонимного чтения ключа.
+// Запрос каталога отдает его индексный документ, ошибки 4xx — документ ошибки ведра,
+// объект с x-amz-website-redirect-location отвечает перенаправлением 301.
+func ServeWebsite(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey, base string, authorize func(objectKey string) error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		s3error.WriteHTMLError(w, r, s3error.ErrMethodNotAllowed)
+		return
+	}
+
+	// 1. Настройки сайта ведра
+	if _, err := os.Stat(filepath.Join(bucketDir, bucketName)); os.IsNotExist(err) {
+		s3error.WriteHTMLError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+	config, found, err := loadWebsite(bucketDir, bucketName)
+	if err != nil {
+		s3error.WriteHTMLError(w, r, err)
+		return
+	}
+	if !found {
+		s3error.WriteHTMLError(w, r, s3error.ErrNoSuchWebsiteConfig)
+		return
+	}
+
+	// 2. Перенаправление всех запросов на другой хост
+	if redirect := config.RedirectAllRequestsTo; redirect != nil {
+		location := requestScheme(r, redirect.Protocol) + "://" + redirect.HostName + "/" + objectKey
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return
+	}
+
+	// 3. Правила без условия на код ошибки применяются до чтения объекта
+	if rule, ok := matchRoutingRule(config.RoutingRules, objectKey, 0); ok {
+		rule.redirect(w, r, objectKey, base)
+		return
+	}
+
+	// 4. Чтение объекта; запрос каталога отдает индексный документ
+	key := objectKey
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += config.IndexDocument.Suffix
+	}
+	err = serveWebsiteObject(w, r, bucketDir, bucketName, key, base, http.StatusOK, authorize)
+	if err == nil {
+		return
+	}
+	// Ключ без косой черты, для которого есть индексный документ, — это каталог
+	if errors.Is(err, s3error.ErrNoSuchKey) && key == objectKey {
+		if _, _, indexErr := lookupVersion(bucketDir, bucketName, objectKey+"/"+config.IndexDocument.Suffix, ""); indexErr == nil {
+			http.Redirect(w, r, base+objectKey+"/", http.StatusFound)
+			return
+		}
+	}
+
+	// 5. Ошибка: правило для ее кода, документ ошибки ведра или стандартная страница
+	var s3Err *s3error.Error
+	if !errors.As(err, &s3Err) {
+		s3error.WriteHTMLError(w, r, err)
+		return
+	}
+	if rule, ok := matchRoutingRule(config.RoutingRules, objectKey, s3Err.StatusCode); ok {
+		rule.redirect(w, r, objectKey, base)
+		return
+	}
+	if config.ErrorDocument != nil && s3Err.StatusCode >= 400 && s3Err.StatusCode < 500 {
+		w.Header().Del("Content-Range")
+		if serveWebsiteObject(w, r, bucketDir, bucketName, config.ErrorDocument.Key, base, s3Err.StatusCode, authorize) == nil {
+			return
+		}
+	}
+	s3error.WriteHTMLError(w, r, s3Err)
+}
+
+// serveWebsiteObject отдает текущую версию ключа с указанным статусом. Ошибка возвращается,
+// только если ответ еще не начат. Адрес x-amz-website-redirect-location вида "/путь"
+// считается от корня сайта.
+func serveWebsiteObject(w http.ResponseWriter, r *http.Request, bucketDir, bucketName, objectKey, base string, status int, authorize func(objectKey string) error) error {
+	if err := authorize(objectKey); err != nil {
+		return err
+	}
+	record, file, err := openVersion(bucketDir, bucketName, objectKey, "")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if location := record.Metadata[websiteRedirectHeader]; location != "" && status == http.StatusOK {
+		if strings.HasPrefix(location, "/") {
+			location = base + strings.TrimPrefix(location, "/")
+		}
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return nil
+	}
+	return writeObject(w, r, record, file, status)
+}
+
+// matchRoutingRule находит первое правило, условие которого выполняется для ключа.
+// errorCode == 0 выбирает правила без условия на код ошибки.
+func matchRoutingRule(rules []RoutingRule, objectKey string, errorCode int) (RoutingRule, bool) {
+	for _, rule := range rules {
+		condition := RoutingCondition{}
+		if rule.Condition != nil {
+			condition = *rule.Condition
+		}
+		if !strings.HasPrefix(objectKey, condition.KeyPrefixEquals) {
+			continue
+		}
+		if errorCode == 0 && condition.HTTPErrorCodeReturnedEquals == "" {
+			return rule, true
+		}
+		if errorCode != 0 && condition.HTTPErrorCodeReturnedEquals == strconv.Itoa(errorCode) {
+			return rule, true
+		}
+	}
+	return RoutingRule{}, false
+}
+
+// redirect перенаправляет запрос по правилу: заменяет ключ или его префикс, а при заданном
+// HostName — хост и протокол. По умолчанию используется код 301.
+func (rule RoutingRule) redirect(w http.ResponseWriter, r *http.Request, objectKey, base string) {
+	redirect := rule.Redirect
+	key := objectKey
+	if redirect.ReplaceKeyWith != nil {
+		key = *redirect.ReplaceKeyWith
+	} else if redirect.ReplaceKeyPrefixWith != nil {
+		prefix := ""
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = *redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(objectKey, prefix)
+	}
+
+	location := base + key
+	if redirect.HostName != "" || redirect.Protocol != "" {
+		host := redirect.HostName
+		if host == "" {
+			host = r.Host
+		} else {
+			// Другой хост отдает сайт от корня
+			location = "/" + key
+		}
+		location = requestScheme(r, redirect.Protocol) + "://" + host + location
+	}
+
+	status := http.StatusMovedPermanently
+	if code, err := strconv.Atoi(redirect.HTTPRedirectCode); err == nil {
+		status = code
+	}
+	http.Redirect(w, r, location, status)
+}
+
+// requestScheme возвращает протокол перенаправления: заданный или протокол запроса
+func requestScheme(r *http.Request, protocol string) string {
+	if protocol != "" {
+		return protocol
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,268 @@
+package policy
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// arnPrefix — префикс ARN ресурсов S3
+const arnPrefix = "arn:aws:s3:::"
+
+// ifExistsSuffix — суффикс оператора, при котором отсутствующий ключ не нарушает условие
+const ifExistsSuffix = "IfExists"
+
+// Decision — результат оценки политики
+type Decision int
+
+const (
+	// NotApplicable — ни одно утверждение не относится к запросу
+	NotApplicable Decision = iota
+	Allow
+	Deny
+)
+
+// Request — запрос в терминах политики
+type Request struct {
+	// Principal — ключ доступа отправителя; пустая строка означает анонимный запрос
+	Principal string
+	Action    string
+	Resource  string
+	// Conditions — значения ключей условий, например aws:SourceIp или s3:prefix
+	Conditions map[string][]string
+}
+
+// BucketARN возвращает ARN ведра
+func BucketARN(bucketName string) string {
+	return arnPrefix + bucketName
+}
+
+// ObjectARN возвращает ARN объекта
+func ObjectARN(bucketName, objectKey string) string {
+	return arnPrefix + bucketName + "/" + objectKey
+}
+
+// Evaluate оценивает запрос по всем утверждениям политики.
+// Явный запрет имеет приоритет над любым разрешением.
+func (p *Policy) Evaluate(request Request) Decision {
+	decision := NotApplicable
+	for _, statement := range p.Statements {
+		if !statement.applies(request) {
+			continue
+		}
+		if statement.Effect == EffectDeny {
+			return Deny
+		}
+		decision = Allow
+	}
+	return decision
+}
+
+// applies проверяет субъект, действие, ресурс и условия утверждения
+func (s Statement) applies(request Request) bool {
+	if !s.matchesPrincipal(request.Principal) {
+		return false
+	}
+	if !matchesAny(s.Action, strings.ToLower(request.Action), strings.ToLower) {
+		return false
+	}
+	if !matchesAny(s.Resource, request.Resource, nil) {
+		return false
+	}
+	for operator, keys := range s.Condition {
+		for key, values := range keys {
+			actual, present := request.lookup(key)
+			if !evaluateCondition(operator, values, actual, present) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesPrincipal проверяет субъект: "*" относится ко всем, в том числе к анонимным запросам
+func (s Statement) matchesPrincipal(principal string) bool {
+	for _, candidate := range s.Principal.AWS {
+		if candidate == "*" || (principal != "" && candidate == principal) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup возвращает значения ключа условия; имена ключей не зависят от регистра
+func (r Request) lookup(key string) ([]string, bool) {
+	for name, values := range r.Conditions {
+		if strings.EqualFold(name, key) {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+// matchesAny сопоставляет значение с шаблонами; normalize приводит шаблон к виду значения
+func matchesAny(patterns []string, value string, normalize func(string) string) bool {
+	for _, pattern := range patterns {
+		if normalize != nil {
+			pattern = normalize(pattern)
+		}
+		if matchWildcard(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard сопоставляет строку с шаблоном, где '*' — любая подстрока, '?' — любой символ
+func matchWildcard(pattern, value string) bool {
+	p, v := []rune(pattern), []rune(value)
+	// Индексы последней '*' в шаблоне и позиции в строке, с которой она сопоставлена
+	star, mark := -1, 0
+	i, j := 0, 0
+	for j < len(v) {
+		switch {
+		case i < len(p) && (p[i] == '?' || p[i] == v[j]):
+			i++
+			j++
+		case i < len(p) && p[i] == '*':
+			star, mark = i, j
+			i++
+		case star >= 0:
+			mark++
+			i, j = star+1, mark
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
+
+// conditionOperator описывает оператор условия без суффикса IfExists
+type conditionOperator struct {
+	// parse проверяет значение из политики
+	parse func(string) error
+	// match сравнивает значение из политики со значением запроса
+	match func(expected, actual string) bool
+	// negated — оператор вида Not...: выполняется, если ни одно значение не совпало
+	negated bool
+}
+
+// operators — поддерживаемые операторы условий
+var operators = map[string]conditionOperator{
+	"StringEquals":              {parseNothing, stringEquals, false},
+	"StringNotEquals":           {parseNothing, stringEquals, true},
+	"StringEqualsIgnoreCase":    {parseNothing, strings.EqualFold, false},
+	"StringNotEqualsIgnoreCase": {parseNothing, strings.EqualFold, true},
+	"StringLike":                {parseNothing, matchWildcard, false},
+	"StringNotLike":             {parseNothing, matchWildcard, true},
+	"ArnEquals":                 {parseNothing, matchWildcard, false},
+	"ArnNotEquals":              {parseNothing, matchWildcard, true},
+	"ArnLike":                   {parseNothing, matchWildcard, false},
+	"ArnNotLike":                {parseNothing, matchWildcard, true},
+	"NumericEquals":             {parseNumber, compareNumbers(func(c int) bool { return c == 0 }), false},
+	"NumericNotEquals":          {parseNumber, compareNumbers(func(c int) bool { return c == 0 }), true},
+	"NumericLessThan":           {parseNumber, compareNumbers(func(c int) bool { return c > 0 }), false},
+	"NumericLessThanEquals":     {parseNumber, compareNumbers(func(c int) bool { return c >= 0 }), false},
+	"NumericGreaterThan":        {parseNumber, compareNumbers(func(c int) bool { return c < 0 }), false},
+	"NumericGreaterThanEquals":  {parseNumber, compareNumbers(func(c int) bool { return c <= 0 }), false},
+	"DateEquals":                {parseDate, compareDates(func(c int) bool { return c == 0 }), false},
+	"DateNotEquals":             {parseDate, compareDates(func(c int) bool { return c == 0 }), true},
+	"DateLessThan":              {parseDate, compareDates(func(c int) bool { return c > 0 }), false},
+	"DateLessThanEquals":        {parseDate, compareDates(func(c int) bool { return c >= 0 }), false},
+	"DateGreaterThan":           {parseDate, compareDates(func(c int) bool { return c < 0 }), false},
+	"DateGreaterThanEquals":     {parseDate, compareDates(func(c int) bool { return c <= 0 }), false},
+	"Bool":                      {parseBool, strings.EqualFold, false},
+	"IpAddress":                 {parseCIDR, ipInNetwork, false},
+	"NotIpAddress":              {parseCIDR, ipInNetwork, true},
+	"Null":                      {parseBool, nil, false},
+}
+
+// evaluateCondition проверяет одно условие для значений ключа из запроса.
+// Отсутствующий ключ выполняет только условия Null, ...IfExists и операторы вида Not...
+func evaluateCondition(operator string, expected, actual []string, present bool) bool {
+	base, ifExists := strings.CutSuffix(operator, ifExistsSuffix)
+	op := operators[base]
+	if base == "Null" {
+		for _, value := range expected {
+			if isNull, _ := strconv.ParseBool(value); isNull == !present {
+				return true
+			}
+		}
+		return false
+	}
+	if !present {
+		return ifExists || op.negated
+	}
+
+	matched := false
+	for _, value := range actual {
+		for _, pattern := range expected {
+			if op.match(pattern, value) {
+				matched = true
+			}
+		}
+	}
+	return matched != op.negated
+}
+
+func stringEquals(expected, actual string) bool {
+	return expected == actual
+}
+
+// compareNumbers строит сравнение чисел; ok получает знак (expected - actual)
+func compareNumbers(ok func(int) bool) func(expected, actual string) bool {
+	return func(expected, actual string) bool {
+		e, err1 := strconv.ParseFloat(expected, 64)
+		a, err2 := strconv.ParseFloat(actual, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		switch {
+		case e < a:
+			return ok(-1)
+		case e > a:
+			return ok(1)
+		}
+		return ok(0)
+	}
+}
+
+// compareDates строит сравнение дат; ok получает знак (expected - actual)
+func compareDates(ok func(int) bool) func(expected, actual string) bool {
+	return func(expected, actual string) bool {
+		e, err1 := toTime(expected)
+		a, err2 := toTime(actual)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		return ok(e.Compare(a))
+	}
+}
+
+// ipInNetwork проверяет, входит ли адрес запроса в подсеть из политики
+func ipInNetwork(expected, actual string) bool {
+	network, err := toNetwork(expected)
+	ip := net.ParseIP(actual)
+	return err == nil && ip != nil && network.Contains(ip)
+}
+
+// Checker оценивает действие над ресурсом от имени текущего запроса.
+// Нужен обработчикам, которые затрагивают несколько ресурсов, например DeleteObjects.
+type Checker func(action, resource string) Decision
+
+type checkerKey struct{}
+
+// WithChecker сохраняет Checker в контексте запроса
+func WithChecker(ctx context.Context, check Checker) context.Context {
+	return context.WithValue(ctx, checkerKey{}, check)
+}
+
+// CheckerFromContext возвращает Checker запроса; ok == false, если политика не проверялась
+func CheckerFromContext(ctx context.Context) (Checker, bool) {
+	check, ok := ctx.Value(checkerKey{}).(Checker)
+	return check, ok
+}
@@ -0,0 +1,248 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"triple-s/pkg/s3error"
+)
+
+// Допустимые версии языка политик и эффекты утверждений
+const (
+	Version2012 = "2012-10-17"
+	Version2008 = "2008-10-17"
+
+	EffectAllow = "Allow"
+	EffectDeny  = "Deny"
+)
+
+// Policy — политика доступа ведра в формате IAM
+type Policy struct {
+	Version    string        `json:"Version"`
+	ID         string        `json:"Id,omitempty"`
+	Statements StatementList `json:"Statement"`
+}
+
+// Statement — одно утверждение политики
+type Statement struct {
+	Sid       string     `json:"Sid,omitempty"`
+	Effect    string     `json:"Effect"`
+	Principal *Principal `json:"Principal"`
+	Action    StringList `json:"Action"`
+	Resource  StringList `json:"Resource"`
+	// Condition сопоставляет оператору условия ключи и допустимые значения:
+	// {"IpAddress": {"aws:SourceIp": ["10.0.0.0/8"]}}
+	Condition map[string]map[string]StringList `json:"Condition,omitempty"`
+}
+
+// StatementList — одно утверждение или массив утверждений
+type StatementList []Statement
+
+func (l *StatementList) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var statement Statement
+		if err := unmarshalStrict(data, &statement); err != nil {
+			return err
+		}
+		*l = StatementList{statement}
+		return nil
+	}
+	var statements []Statement
+	if err := unmarshalStrict(data, &statements); err != nil {
+		return err
+	}
+	*l = statements
+	return nil
+}
+
+// StringList — строка или массив строк
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*l = StringList{value}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("expected a string or an array of strings")
+	}
+	*l = values
+	return nil
+}
+
+// Principal — субъекты, к которым относится утверждение: "*" или {"AWS": ключи доступа}
+type Principal struct {
+	AWS StringList `json:"AWS"`
+}
+
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		if value != "*" {
+			return fmt.Errorf("invalid principal %q", value)
+		}
+		p.AWS = StringList{"*"}
+		return nil
+	}
+	type plain Principal
+	return unmarshalStrict(data, (*plain)(p))
+}
+
+// Parse разбирает и проверяет политику ведра bucketName.
+// Ошибки возвращаются как MalformedPolicy с описанием причины.
+func Parse(data []byte, bucketName string) (*Policy, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil, s3error.ErrMalformedPolicy
+	}
+	var policy Policy
+	if err := unmarshalStrict(data, &policy); err != nil {
+		return nil, s3error.ErrMalformedPolicy.WithMessage("Policy is not valid JSON: " + err.Error())
+	}
+	if err := policy.validate(bucketName); err != nil {
+		return nil, s3error.ErrMalformedPolicy.WithMessage(err.Error())
+	}
+	return &policy, nil
+}
+
+// validate проверяет обязательные поля, действия, ресурсы и условия
+func (p *Policy) validate(bucketName string) error {
+	if p.Version != "" && p.Version != Version2012 && p.Version != Version2008 {
+		return fmt.Errorf("The policy must contain a valid version string")
+	}
+	if len(p.Statements) == 0 {
+		return fmt.Errorf("Could not parse the policy: Statement is empty!")
+	}
+	sids := make(map[string]bool, len(p.Statements))
+	for _, statement := range p.Statements {
+		if statement.Sid != "" {
+			if sids[statement.Sid] {
+				return fmt.Errorf("Statement IDs (SID) in a single policy must be unique")
+			}
+			sids[statement.Sid] = true
+		}
+		if statement.Effect != EffectAllow && statement.Effect != EffectDeny {
+			return fmt.Errorf("Invalid effect: %s", statement.Effect)
+		}
+		if statement.Principal == nil || len(statement.Principal.AWS) == 0 {
+			return fmt.Errorf("Policy has an invalid principal")
+		}
+		if len(statement.Action) == 0 {
+			return fmt.Errorf("Policy has no actions")
+		}
+		for _, action := range statement.Action {
+			if action != "*" && !strings.HasPrefix(strings.ToLower(action), "s3:") {
+				return fmt.Errorf("Policy has invalid action: %s", action)
+			}
+		}
+		if len(statement.Resource) == 0 {
+			return fmt.Errorf("Policy has no resources")
+		}
+		for _, resource := range statement.Resource {
+			if !validResource(resource, bucketName) {
+				return fmt.Errorf("Policy has invalid resource: %s", resource)
+			}
+		}
+		for operator, keys := range statement.Condition {
+			if err := validateCondition(operator, keys); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validResource проверяет, что ресурс — ARN этого ведра или его объектов
+func validResource(resource, bucketName string) bool {
+	path, found := strings.CutPrefix(resource, arnPrefix)
+	if !found {
+		return false
+	}
+	bucketPattern, _, _ := strings.Cut(path, "/")
+	return bucketPattern != "" && matchWildcard(bucketPattern, bucketName)
+}
+
+// validateCondition проверяет оператор условия и формат значений
+func validateCondition(operator string, keys map[string]StringList) error {
+	base := strings.TrimSuffix(operator, ifExistsSuffix)
+	op, known := operators[base]
+	if !known {
+		return fmt.Errorf("Invalid Condition type: %s", operator)
+	}
+	for key, values := range keys {
+		if len(values) == 0 {
+			return fmt.Errorf("Condition %s has no values for %s", operator, key)
+		}
+		for _, value := range values {
+			if err := op.parse(value); err != nil {
+				return fmt.Errorf("Invalid value %q for condition %s on %s", value, operator, key)
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalStrict декодирует JSON, отвергая неизвестные поля (NotAction, NotPrincipal и т.п.)
+func unmarshalStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// parseNothing принимает любое значение условия
+func parseNothing(string) error { return nil }
+
+// parseBool проверяет значение условий Bool и Null
+func parseBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+// parseNumber проверяет значение числовых условий
+func parseNumber(value string) error {
+	_, err := strconv.ParseFloat(value, 64)
+	return err
+}
+
+// parseDate проверяет значение условий с датой: RFC 3339 или секунды Unix
+func parseDate(value string) error {
+	_, err := toTime(value)
+	return err
+}
+
+// parseCIDR проверяет адрес или подсеть в условиях IpAddress
+func parseCIDR(value string) error {
+	_, err := toNetwork(value)
+	return err
+}
+
+// toTime разбирает дату в формате RFC 3339 или секунды Unix
+func toTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// toNetwork разбирает подсеть CIDR; одиночный адрес считается подсетью из одного адреса
+func toNetwork(value string) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", value)
+		}
+		bits := 8 * net.IPv4len
+		if ip.To4() == nil {
+			bits = 8 * net.IPv6len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	return network, err
+}
@@ -0,0 +1,193 @@
+package s3error
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Error — типизированная ошибка хранилища с кодом S3 и HTTP-статусом
+type Error struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// WithMessage возвращает копию ошибки с уточненным сообщением
+func (e *Error) WithMessage(message string) *Error {
+	return &Error{Code: e.Code, Message: message, StatusCode: e.StatusCode}
+}
+
+// Is сравнивает ошибки по коду, чтобы errors.Is работал для копий из WithMessage
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Стандартные ошибки S3
+var (
+	ErrAccessDenied           = &Error{"AccessDenied", "Access Denied.", http.StatusForbidden}
+	ErrAccessForbidden        = &Error{"AccessForbidden", "CORSResponse: This CORS request is not allowed. This is usually because the evaluation of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.", http.StatusForbidden}
+	ErrAuthorizationMalformed = &Error{"AuthorizationHeaderMalformed", "The authorization header you provided is invalid.", http.StatusBadRequest}
+	ErrBadDigest              = &Error{"BadDigest", "The Content-MD5 you specified did not match what we received.", http.StatusBadRequest}
+	ErrBucketAlreadyExists    = &Error{"BucketAlreadyExists", "The requested bucket name is not available.", http.StatusConflict}
+	ErrBucketNotEmpty         = &Error{"BucketNotEmpty", "The bucket you tried to delete is not empty.", http.StatusConflict}
+	ErrContentSHA256Mismatch  = &Error{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	ErrEntityTooSmall         = &Error{"EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.", http.StatusBadRequest}
+	ErrIllegalVersioning      = &Error{"IllegalVersioningConfigurationException", "The versioning configuration specified in the request is invalid.", http.StatusBadRequest}
+	ErrIncompleteBody         = &Error{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	ErrInternalError          = &Error{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+	ErrInvalidAccessKeyID     = &Error{"InvalidAccessKeyId", "The AWS access key ID you provided does not exist in our records.", http.StatusForbidden}
+	ErrInvalidArgument        = &Error{"InvalidArgument", "Invalid Argument.", http.StatusBadRequest}
+	ErrInvalidBucketName      = &Error{"InvalidBucketName", "The specified bucket is not valid.", http.StatusBadRequest}
+	ErrInvalidBucketState     = &Error{"InvalidBucketState", "The request is not valid with the current state of the bucket.", http.StatusConflict}
+	ErrInvalidDigest          = &Error{"InvalidDigest", "The Content-MD5 you specified is not valid.", http.StatusBadRequest}
+	ErrInvalidEncryptionAlg   = &Error{"InvalidEncryptionAlgorithmError", "The encryption request you specified is not valid. The valid value is AES256.", http.StatusBadRequest}
+	ErrInvalidPart            = &Error{"InvalidPart", "One or more of the specified parts could not be found.", http.StatusBadRequest}
+	ErrInvalidPartOrder       = &Error{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+	ErrInvalidRange           = &Error{"InvalidRange", "The requested range is not satisfiable.", http.StatusRequestedRangeNotSatisfiable}
+	ErrInvalidTag             = &Error{"InvalidTag", "The tag provided was not a valid tag.", http.StatusBadRequest}
+	ErrInvalidRequest         = &Error{"InvalidRequest", "Invalid Request.", http.StatusBadRequest}
+	ErrInvalidURI             = &Error{"InvalidURI", "Couldn't parse the specified URI.", http.StatusBadRequest}
+	ErrKMSNotFound            = &Error{"KMS.NotFoundException", "Invalid keyId.", http.StatusBadRequest}
+	ErrKeyTooLong             = &Error{"KeyTooLongError", "Your key is too long.", http.StatusBadRequest}
+	ErrMalformedACL           = &Error{"MalformedACLError", "The XML you provided was not well-formed or did not validate against our published schema.", http.StatusBadRequest}
+	ErrMalformedPolicy        = &Error{"MalformedPolicy", "Policies must be valid JSON and the first byte must be '{'", http.StatusBadRequest}
+	ErrMalformedXML           = &Error{"MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.", http.StatusBadRequest}
+	ErrMetadataTooLarge       = &Error{"MetadataTooLarge", "Your metadata headers exceed the maximum allowed metadata size.", http.StatusBadRequest}
+	ErrMethodNotAllowed       = &Error{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	ErrMissingContentLength   = &Error{"MissingContentLength", "You must provide the Content-Length HTTP header.", http.StatusLengthRequired}
+	ErrMissingSecurityHeader  = &Error{"MissingSecurityHeader", "Your request is missing a required header.", http.StatusBadRequest}
+	ErrNoObjectLockConfig     = &Error{"ObjectLockConfigurationNotFoundError", "Object Lock configuration does not exist for this bucket", http.StatusNotFound}
+	ErrNoSuchBucket           = &Error{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	ErrNoSuchBucketPolicy     = &Error{"NoSuchBucketPolicy", "The bucket policy does not exist.", http.StatusNotFound}
+	ErrNoSuchCORSConfig       = &Error{"NoSuchCORSConfiguration", "The CORS configuration does not exist.", http.StatusNotFound}
+	ErrNoSuchEncryptionConfig = &Error{"ServerSideEncryptionConfigurationNotFoundError", "The server side encryption configuration was not found.", http.StatusNotFound}
+	ErrNoSuchKey              = &Error{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	ErrNoSuchLifecycle        = &Error{"NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist.", http.StatusNotFound}
+	ErrNoSuchObjectLock       = &Error{"NoSuchObjectLockConfiguration", "The specified object does not have a ObjectLock configuration", http.StatusNotFound}
+	ErrNoSuchTagSet           = &Error{"NoSuchTagSet", "The TagSet does not exist.", http.StatusNotFound}
+	ErrNoSuchUpload           = &Error{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	ErrNoSuchVersion          = &Error{"NoSuchVersion", "The specified version does not exist.", http.StatusNotFound}
+	ErrNoSuchWebsiteConfig    = &Error{"NoSuchWebsiteConfiguration", "The specified bucket does not have a website configuration", http.StatusNotFound}
+	ErrNotImplemented         = &Error{"NotImplemented", "A header you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	ErrPreconditionFailed     = &Error{"PreconditionFailed", "At least one of the pre-conditions you specified did not hold.", http.StatusPreconditionFailed}
+	ErrRequestTimeTooSkewed   = &Error{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	ErrSignatureDoesNotMatch  = &Error{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your key and signing method.", http.StatusForbidden}
+)
+
+// ErrorResponse — XML-документ ошибки в формате S3
+type ErrorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// RequestIDHeader — заголовок с идентификатором запроса
+const RequestIDHeader = "X-Amz-Request-Id"
+
+// NewRequestID генерирует идентификатор запроса
+func NewRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "0000000000000000"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// WriteError отправляет ошибку клиенту в виде XML-документа S3.
+// Нетипизированные ошибки считаются внутренними и записываются в журнал.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	s3Err, requestID := resolve(w, r, err)
+
+	response := ErrorResponse{
+		Code:      s3Err.Code,
+		Message:   s3Err.Message,
+		Resource:  r.URL.Path,
+		RequestID: requestID,
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(s3Err.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(response)
+}
+
+// WriteHTMLError отправляет ошибку в виде HTML-страницы, как website-эндпоинт S3
+func WriteHTMLError(w http.ResponseWriter, r *http.Request, err error) {
+	s3Err, requestID := resolve(w, r, err)
+
+	status := strconv.Itoa(s3Err.StatusCode) + " " + http.StatusText(s3Err.StatusCode)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<html>\n<head><title>%s</title></head>\n<body>\n<h1>%s</h1>\n<ul>\n", status, status)
+	fmt.Fprintf(&buf, "<li>Code: %s</li>\n<li>Message: %s</li>\n", html.EscapeString(s3Err.Code), html.EscapeString(s3Err.Message))
+	fmt.Fprintf(&buf, "<li>RequestId: %s</li>\n</ul>\n<hr/>\n</body>\n</html>\n", requestID)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(s3Err.StatusCode)
+	if r.Method != http.MethodHead {
+		w.Write(buf.Bytes())
+	}
+}
+
+// resolve приводит ошибку к типизированной и возвращает идентификатор запроса,
+// при необходимости создавая его. Нетипизированная ошибка записывается в журнал как внутренняя.
+func resolve(w http.ResponseWriter, r *http.Request, err error) (*Error, string) {
+	var s3Err *Error
+	if !errors.As(err, &s3Err) {
+		log.Printf("internal error: %s %s: %v", r.Method, r.URL.Path, err)
+		s3Err = ErrInternalError
+	}
+
+	requestID := w.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		requestID = NewRequestID()
+		w.Header().Set(RequestIDHeader, requestID)
+	}
+	return s3Err, requestID
+}
+
+// Namespace — пространство имен XML документов S3
+const Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// XMLName возвращает имя корневого элемента ответа в пространстве имен S3.
+// Конфигурации, которые сервер и принимает в теле запроса, и отдает в ответе, объявляют
+// XMLName без тега: тег с пространством имен отклонил бы тела запросов без xmlns.
+// Пространство имен таким документам задается перед отправкой через XMLName.
+func XMLName(local string) xml.Name {
+	return xml.Name{Space: Namespace, Local: local}
+}
+
+// WriteXML кодирует v в XML и отправляет его с указанным статусом.
+// Документ кодируется заранее, чтобы ошибку кодирования можно было вернуть клиенту как InternalError.
+func WriteXML(w http.ResponseWriter, r *http.Request, status int, v any) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(v); err != nil {
+		WriteError(w, r, fmt.Errorf("unable to encode XML: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,414 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"triple-s/pkg/acl"
+	"triple-s/pkg/auth"
+	"triple-s/pkg/bucket"
+	"triple-s/pkg/object"
+	"triple-s/pkg/policy"
+	"triple-s/pkg/s3error"
+)
+
+// conditionHeaders — заголовки запроса, доступные в условиях политики как s3:<заголовок>
+var conditionHeaders = []string{
+	"x-amz-acl",
+	"x-amz-copy-source",
+	"x-amz-metadata-directive",
+	"x-amz-server-side-encryption",
+	"x-amz-server-side-encryption-aws-kms-key-id",
+	"x-amz-storage-class",
+}
+
+// authorize проверяет запрос по политике ведра и ACL и возвращает запрос с Checker в контексте.
+// Явный запрет политики отклоняет запрос с AccessDenied. При включенной аутентификации запрос,
+// который политика явно не разрешила, должен быть разрешен ACL ведра или объекта; без
+// аутентификации ACL не проверяются, и запрос без запрета разрешается, как и раньше.
+func authorize(r *http.Request, dataDir, bucketName, objectKey string) (*http.Request, error) {
+	identity, enforced := auth.IdentityFromContext(r.Context())
+	principal := ""
+	if enforced {
+		principal = identity.AccessKey
+	}
+	if bucketName == "" {
+		// Список ведер доступен только аутентифицированным отправителям
+		if enforced && identity.Anonymous() {
+			return nil, s3error.ErrAccessDenied
+		}
+		return r, nil
+	}
+	bucketPolicy, err := object.LoadBucketPolicy(dataDir, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := requestConditions(r, principal)
+	check := func(action, resource string) policy.Decision {
+		if bucketPolicy == nil {
+			return policy.NotApplicable
+		}
+		return bucketPolicy.Evaluate(policy.Request{
+			Principal:  principal,
+			Action:     action,
+			Resource:   resource,
+			Conditions: conditions,
+		})
+	}
+
+	// 1. Действие над самим ресурсом запроса
+	decision := policy.NotApplicable
+	if action := requestAction(r, objectKey); action != "" {
+		resource := policy.BucketARN(bucketName)
+		if objectKey != "" {
+			resource = policy.ObjectARN(bucketName, objectKey)
+		}
+		decision = check(action, resource)
+		if decision == policy.Deny {
+			return nil, s3error.ErrAccessDenied
+		}
+	}
+	if enforced && decision != policy.Allow {
+		if err := authorizeACL(r, dataDir, bucketName, objectKey, principal); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Копирование требует права на чтение источника по политике и ACL его ведра
+	if source := r.Header.Get("X-Amz-Copy-Source"); source != "" && r.Method == http.MethodPut && objectKey != "" {
+		if err := authorizeCopySource(source, dataDir, principal, enforced, conditions); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.WithContext(policy.WithChecker(r.Context(), check)), nil
+}
+
+// authorizeCopySource проверяет s3:GetObject и право READ для источника копирования.
+// Некорректный заголовок пропускается: его отклонит CopyObject. Некорректное имя ведра
+// источника отклоняется сразу, до чтения его политики.
+func authorizeCopySource(source, dataDir, principal string, enforced bool, conditions map[string][]string) error {
+	source, versionID, _ := strings.Cut(source, "?versionId=")
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return nil
+	}
+	srcBucket, srcKey, found := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !found || srcBucket == "" {
+		return nil
+	}
+	if err := bucket.ValidateName(srcBucket); err != nil {
+		return err
+	}
+	srcPolicy, err := object.LoadBucketPolicy(dataDir, srcBucket)
+	if err != nil {
+		return err
+	}
+	decision := policy.NotApplicable
+	if srcPolicy != nil {
+		action := "s3:GetObject"
+		if versionID != "" {
+			action = "s3:GetObjectVersion"
+		}
+		decision = srcPolicy.Evaluate(policy.Request{
+			Principal:  principal,
+			Action:     action,
+			Resource:   policy.ObjectARN(srcBucket, srcKey),
+			Conditions: conditions,
+		})
+	}
+	if decision == policy.Deny {
+		return s3error.ErrAccessDenied
+	}
+	if enforced && decision != policy.Allow {
+		return authorizeObjectACL(dataDir, srcBucket, srcKey, versionID, principal, acl.PermissionRead)
+	}
+	return nil
+}
+
+// authorizeACL проверяет право, которое запрос требует от ACL ведра или объекта
+func authorizeACL(r *http.Request, dataDir, bucketName, objectKey, principal string) error {
+	// Создать ведро может любой аутентифицированный отправитель
+	if objectKey == "" && r.Method == http.MethodPut && !hasBucketSubresource(r.URL.Query()) {
+		if principal == "" {
+			return s3error.ErrAccessDenied
+		}
+		return nil
+	}
+
+	permission, onObject := requiredPermission(r, objectKey)
+	if onObject {
+		return authorizeObjectACL(dataDir, bucketName, objectKey, r.URL.Query().Get("versionId"), principal, permission)
+	}
+	bucketACL, err := acl.LoadBucket(dataDir, bucketName)
+	if err != nil {
+		return err
+	}
+	if !allows(bucketACL, principal, permission) {
+		return s3error.ErrAccessDenied
+	}
+	return nil
+}
+
+// authorizeObjectACL проверяет право на версию объекта. Если версии нет, решает право READ
+// на ведро: с ним обработчик вернет NoSuchKey, без него существование ключа не раскрывается.
+func authorizeObjectACL(dataDir, bucketName, objectKey, versionID, principal, permission string) error {
+	objectACL, err := object.LookupObjectACL(dataDir, bucketName, objectKey, versionID)
+	if err != nil {
+		bucketACL, err := acl.LoadBucket(dataDir, bucketName)
+		if err != nil {
+			return err
+		}
+		objectACL, permission = bucketACL, acl.PermissionRead
+	}
+	if !allows(objectACL, principal, permission) {
+		return s3error.ErrAccessDenied
+	}
+	return nil
+}
+
+// allows проверяет право по ACL; пустое право означает, что нужен владелец ресурса
+func allows(a acl.ACL, principal, permission string) bool {
+	if permission == "" {
+		return a.IsOwner(principal)
+	}
+	return a.Allows(principal, permission)
+}
+
+// requiredPermission возвращает право ACL, нужное для запроса, и признак того, что оно
+// проверяется по ACL объекта. Пустое право означает, что запрос доступен только владельцу:
+// так устроены конфигурации ведра, теги и удержание объекта.
+func requiredPermission(r *http.Request, objectKey string) (permission string, onObject bool) {
+	query := r.URL.Query()
+	if objectKey == "" {
+		switch r.Method {
+		case http.MethodGet:
+			switch {
+			case query.Has("acl"):
+				return acl.PermissionReadACP, false
+			case query.Has("uploads"), query.Has("versions"), !hasBucketSubresource(query):
+				return acl.PermissionRead, false
+			}
+		case http.MethodHead:
+			return acl.PermissionRead, false
+		case http.MethodPut:
+			if query.Has("acl") {
+				return acl.PermissionWriteACP, false
+			}
+		case http.MethodPost:
+			return acl.PermissionWrite, false
+		}
+		return "", false
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		switch {
+		case query.Has("acl"):
+			return acl.PermissionWriteACP, true
+		case query.Has("tagging"), query.Has("retention"), query.Has("legal-hold"):
+			return "", true
+		}
+	case http.MethodGet:
+		switch {
+		case query.Has("acl"):
+			return acl.PermissionReadACP, true
+		case query.Has("uploadId"):
+			return acl.PermissionWrite, false
+		}
+		return acl.PermissionRead, true
+	case http.MethodHead:
+		return acl.PermissionRead, true
+	case http.MethodDelete:
+		if query.Has("tagging") {
+			return "", true
+		}
+	}
+	// Запись, удаление и multipart-загрузки требуют права WRITE на ведро
+	return acl.PermissionWrite, false
+}
+
+// bucketSubresources — подресурсы конфигурации ведра
+var bucketSubresources = []string{"versioning", "lifecycle", "tagging", "cors", "policy", "acl", "encryption", "object-lock", "website"}
+
+// hasBucketSubresource сообщает, что запрос к ведру обращается к его конфигурации
+func hasBucketSubresource(query url.Values) bool {
+	for _, name := range bucketSubresources {
+		if query.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestAction возвращает действие S3 для запроса, повторяя выбор обработчика в routeBucket
+// и routeObject. Пустая строка означает, что запрос не проверяется здесь: политику ведра
+// можно изменить всегда, а DeleteObjects проверяет каждый ключ сам.
+func requestAction(r *http.Request, objectKey string) string {
+	query := r.URL.Query()
+	if objectKey == "" {
+		return bucketAction(r.Method, query)
+	}
+
+	versioned := query.Get("versionId") != ""
+	pick := func(current, version string) string {
+		if versioned {
+			return version
+		}
+		return current
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if query.Has("acl") {
+			return pick("s3:PutObjectAcl", "s3:PutObjectVersionAcl")
+		} else if query.Has("tagging") {
+			return pick("s3:PutObjectTagging", "s3:PutObjectVersionTagging")
+		} else if query.Has("retention") {
+			return "s3:PutObjectRetention"
+		} else if query.Has("legal-hold") {
+			return "s3:PutObjectLegalHold"
+		}
+		return "s3:PutObject"
+	case http.MethodPost:
+		return "s3:PutObject"
+	case http.MethodGet:
+		if query.Has("acl") {
+			return pick("s3:GetObjectAcl", "s3:GetObjectVersionAcl")
+		} else if query.Has("tagging") {
+			return pick("s3:GetObjectTagging", "s3:GetObjectVersionTagging")
+		} else if query.Has("retention") {
+			return "s3:GetObjectRetention"
+		} else if query.Has("legal-hold") {
+			return "s3:GetObjectLegalHold"
+		} else if query.Has("uploadId") {
+			return "s3:ListMultipartUploadParts"
+		}
+		return pick("s3:GetObject", "s3:GetObjectVersion")
+	case http.MethodHead:
+		return pick("s3:GetObject", "s3:GetObjectVersion")
+	case http.MethodDelete:
+		if query.Has("tagging") {
+			return pick("s3:DeleteObjectTagging", "s3:DeleteObjectVersionTagging")
+		} else if query.Has("uploadId") {
+			return "s3:AbortMultipartUpload"
+		}
+		return pick("s3:DeleteObject", "s3:DeleteObjectVersion")
+	}
+	return ""
+}
+
+// bucketAction возвращает действие S3 для запроса к ведру
+func bucketAction(method string, query url.Values) string {
+	if query.Has("policy") {
+		return ""
+	}
+	switch method {
+	case http.MethodPut:
+		switch {
+		case query.Has("versioning"):
+			return "s3:PutBucketVersioning"
+		case query.Has("lifecycle"):
+			return "s3:PutLifecycleConfiguration"
+		case query.Has("tagging"):
+			return "s3:PutBucketTagging"
+		case query.Has("cors"):
+			return "s3:PutBucketCORS"
+		case query.Has("acl"):
+			return "s3:PutBucketAcl"
+		case query.Has("encryption"):
+			return "s3:PutEncryptionConfiguration"
+		case query.Has("object-lock"):
+			return "s3:PutBucketObjectLockConfiguration"
+		case query.Has("website"):
+			return "s3:PutBucketWebsite"
+		}
+		return "s3:CreateBucket"
+	case http.MethodDelete:
+		// Как и в S3, удаление конфигурации требует права на ее запись
+		switch {
+		case query.Has("lifecycle"):
+			return "s3:PutLifecycleConfiguration"
+		case query.Has("tagging"):
+			return "s3:PutBucketTagging"
+		case query.Has("cors"):
+			return "s3:PutBucketCORS"
+		case query.Has("encryption"):
+			return "s3:PutEncryptionConfiguration"
+		case query.Has("website"):
+			return "s3:DeleteBucketWebsite"
+		}
+		return "s3:DeleteBucket"
+	case http.MethodGet:
+		switch {
+		case query.Has("uploads"):
+			return "s3:ListBucketMultipartUploads"
+		case query.Has("versioning"):
+			return "s3:GetBucketVersioning"
+		case query.Has("versions"):
+			return "s3:ListBucketVersions"
+		case query.Has("lifecycle"):
+			return "s3:GetLifecycleConfiguration"
+		case query.Has("tagging"):
+			return "s3:GetBucketTagging"
+		case query.Has("cors"):
+			return "s3:GetBucketCORS"
+		case query.Has("acl"):
+			return "s3:GetBucketAcl"
+		case query.Has("encryption"):
+			return "s3:GetEncryptionConfiguration"
+		case query.Has("object-lock"):
+			return "s3:GetBucketObjectLockConfiguration"
+		case query.Has("website"):
+			return "s3:GetBucketWebsite"
+		}
+		return "s3:ListBucket"
+	case http.MethodHead:
+		return "s3:ListBucket"
+	}
+	return ""
+}
+
+// requestConditions собирает значения ключей условий политики для запроса
+func requestConditions(r *http.Request, principal string) map[string][]string {
+	now := time.Now().UTC()
+	conditions := map[string][]string{
+		"aws:CurrentTime":     {now.Format(time.RFC3339)},
+		"aws:EpochTime":       {strconv.FormatInt(now.Unix(), 10)},
+		"aws:SecureTransport": {strconv.FormatBool(r.TLS != nil)},
+		"aws:PrincipalType":   {"Anonymous"},
+	}
+	if principal != "" {
+		conditions["aws:PrincipalType"] = []string{"User"}
+		conditions["aws:userid"] = []string{principal}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		conditions["aws:SourceIp"] = []string{host}
+	}
+	if userAgent := r.UserAgent(); userAgent != "" {
+		conditions["aws:UserAgent"] = []string{userAgent}
+	}
+	if referer := r.Referer(); referer != "" {
+		conditions["aws:Referer"] = []string{referer}
+	}
+
+	query := r.URL.Query()
+	for _, name := range []string{"prefix", "delimiter", "max-keys"} {
+		if query.Has(name) {
+			conditions["s3:"+name] = []string{query.Get(name)}
+		}
+	}
+	if versionID := query.Get("versionId"); versionID != "" {
+		conditions["s3:VersionId"] = []string{versionID}
+	}
+	for _, name := range conditionHeaders {
+		if value := r.Header.Get(name); value != "" {
+			conditions["s3:"+name] = []string{value}
+		}
+	}
+	return conditions
+}
@@ -2,48 +2,237 @@ package server
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"triple-s/pkg/auth"
 	"triple-s/pkg/bucket"
 	"triple-s/pkg/object"
+	"triple-s/pkg/s3error"
 )
 
-func SetupRoutes(dataDir string) http.Handler {
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		pathParts := strings.Split(r.URL.Path, "/")
-		switch len(pathParts) {
-		case 2:
-			bucketName := pathParts[1]
-			if r.Method == http.MethodPut {
-				bucket.CreateBucketHandler(w, r, dataDir, bucketName)
-			} else if r.Method == http.MethodDelete {
-				bucket.DeleteBucketHandler(w, r, dataDir, bucketName)
-			} else if r.Method == http.MethodGet {
-				bucket.ListAllBucketsHandler(w, r, dataDir)
-			} else {
-				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+// SetupRoutes возвращает обработчик всех запросов к хранилищу.
+// http.ServeMux не используется: он нормализует путь ("a//b", "a/../b") и отвечает
+// редиректом, а такие последовательности допустимы внутри ключа объекта.
+// Если задан baseDomain, запросы к хосту <bucket>.<baseDomain> адресуют ведро через хост
+// (virtual-hosted style); остальные запросы разбираются по пути, как и раньше.
+// Запросы к хосту <bucket>.<websiteDomain> отдаются как сайт ведра.
+func SetupRoutes(dataDir, baseDomain, websiteDomain string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(s3error.RequestIDHeader, s3error.NewRequestID())
+
+		path, found := strings.CutPrefix(r.URL.Path, "/")
+		if !found {
+			s3error.WriteError(w, r, s3error.ErrInvalidURI)
+			return
+		}
+		if websiteBucket, ok := bucketFromHost(r.Host, websiteDomain); ok {
+			serveWebsite(w, r, dataDir, websiteBucket, path, "/")
+			return
+		}
+		var bucketName, objectKey string
+		if hostBucket, ok := bucketFromHost(r.Host, baseDomain); ok {
+			// Ведро задано хостом, весь путь — ключ объекта
+			bucketName, objectKey = hostBucket, path
+		} else {
+			// Первый сегмент — имя ведра, остаток пути целиком — ключ объекта
+			bucketName, objectKey, _ = strings.Cut(path, "/")
+		}
+		// Ключ может содержать "..", но имя ведра — каталог в dataDir и проверяется до обработчиков
+		if bucketName != "" {
+			if err := bucket.ValidateName(bucketName); err != nil {
+				s3error.WriteError(w, r, err)
+				return
 			}
-		case 3:
-			bucketName := pathParts[1]
-			objectKey := pathParts[2]
-			if r.Method == http.MethodPut {
-				object.UploadObjectHandler(w, r, dataDir, bucketName, objectKey)
-			} else if r.Method == http.MethodGet {
-				object.RetrieveObjectHandler(w, r, dataDir, bucketName, objectKey)
-			} else if r.Method == http.MethodDelete {
-				object.DeleteObjectHandler(w, r, dataDir, bucketName, objectKey)
-			} else {
-				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+
+		// Предварительный запрос CORS и заголовки Access-Control-* оцениваются по правилам ведра
+		if bucketName != "" {
+			if r.Method == http.MethodOptions {
+				object.CORSPreflightHandler(w, r, dataDir, bucketName)
+				return
 			}
-		default:
-			http.Error(w, "Bad Request: Invalid URL format", http.StatusBadRequest)
+			object.SetCORSHeaders(w, r, dataDir, bucketName)
+		}
+
+		// Тело aws-chunked декодируется до обработчиков, чтобы они получали исходные данные
+		if err := auth.DecodeStreamingPayload(r); err != nil {
+			s3error.WriteError(w, r, err)
+			return
+		}
+
+		authorized, err := authorize(r, dataDir, bucketName, objectKey)
+		if err != nil {
+			s3error.WriteError(w, r, err)
+			return
+		}
+		r = authorized
+
+		if objectKey == "" {
+			routeBucket(w, r, dataDir, bucketName)
+		} else {
+			routeObject(w, r, dataDir, bucketName, objectKey)
 		}
 	})
-	return mux
+}
+
+// routeBucket выбирает обработчик для запросов к ведру (/{bucket})
+func routeBucket(w http.ResponseWriter, r *http.Request, dataDir, bucketName string) {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodPut:
+		if query.Has("versioning") {
+			object.PutBucketVersioningHandler(w, r, dataDir, bucketName)
+		} else if query.Has("lifecycle") {
+			object.PutBucketLifecycleHandler(w, r, dataDir, bucketName)
+		} else if query.Has("tagging") {
+			object.PutBucketTaggingHandler(w, r, dataDir, bucketName)
+		} else if query.Has("cors") {
+			object.PutBucketCorsHandler(w, r, dataDir, bucketName)
+		} else if query.Has("policy") {
+			object.PutBucketPolicyHandler(w, r, dataDir, bucketName)
+		} else if query.Has("acl") {
+			object.PutBucketAclHandler(w, r, dataDir, bucketName)
+		} else if query.Has("encryption") {
+			object.PutBucketEncryptionHandler(w, r, dataDir, bucketName)
+		} else if query.Has("object-lock") {
+			object.PutObjectLockConfigurationHandler(w, r, dataDir, bucketName)
+		} else if query.Has("website") {
+			object.PutBucketWebsiteHandler(w, r, dataDir, bucketName)
+		} else {
+			bucket.CreateBucketHandler(w, r, dataDir, bucketName)
+		}
+	case http.MethodDelete:
+		if query.Has("lifecycle") {
+			object.DeleteBucketLifecycleHandler(w, r, dataDir, bucketName)
+		} else if query.Has("tagging") {
+			object.DeleteBucketTaggingHandler(w, r, dataDir, bucketName)
+		} else if query.Has("cors") {
+			object.DeleteBucketCorsHandler(w, r, dataDir, bucketName)
+		} else if query.Has("policy") {
+			object.DeleteBucketPolicyHandler(w, r, dataDir, bucketName)
+		} else if query.Has("encryption") {
+			object.DeleteBucketEncryptionHandler(w, r, dataDir, bucketName)
+		} else if query.Has("website") {
+			object.DeleteBucketWebsiteHandler(w, r, dataDir, bucketName)
+		} else {
+			bucket.DeleteBucketHandler(w, r, dataDir, bucketName)
+		}
+	case http.MethodGet:
+		if bucketName == "" {
+			bucket.ListAllBucketsHandler(w, r, dataDir)
+		} else if query.Has("uploads") {
+			object.ListMultipartUploadsHandler(w, r, dataDir, bucketName)
+		} else if query.Has("versioning") {
+			object.GetBucketVersioningHandler(w, r, dataDir, bucketName)
+		} else if query.Has("versions") {
+			object.ListObjectVersionsHandler(w, r, dataDir, bucketName)
+		} else if query.Has("lifecycle") {
+			object.GetBucketLifecycleHandler(w, r, dataDir, bucketName)
+		} else if query.Has("tagging") {
+			object.GetBucketTaggingHandler(w, r, dataDir, bucketName)
+		} else if query.Has("cors") {
+			object.GetBucketCorsHandler(w, r, dataDir, bucketName)
+		} else if query.Has("policy") {
+			object.GetBucketPolicyHandler(w, r, dataDir, bucketName)
+		} else if query.Has("acl") {
+			object.GetBucketAclHandler(w, r, dataDir, bucketName)
+		} else if query.Has("encryption") {
+			object.GetBucketEncryptionHandler(w, r, dataDir, bucketName)
+		} else if query.Has("object-lock") {
+			object.GetObjectLockConfigurationHandler(w, r, dataDir, bucketName)
+		} else if query.Has("website") {
+			object.GetBucketWebsiteHandler(w, r, dataDir, bucketName)
+		} else {
+			object.ListObjectsHandler(w, r, dataDir, bucketName)
+		}
+	case http.MethodHead:
+		bucket.HeadBucketHandler(w, r, dataDir, bucketName)
+	case http.MethodPost:
+		if query.Has("delete") {
+			object.DeleteObjectsHandler(w, r, dataDir, bucketName)
+		} else {
+			s3error.WriteError(w, r, s3error.ErrMethodNotAllowed)
+		}
+	default:
+		s3error.WriteError(w, r, s3error.ErrMethodNotAllowed)
+	}
+}
+
+// routeObject выбирает обработчик для запросов к объекту (/{bucket}/{key})
+func routeObject(w http.ResponseWriter, r *http.Request, dataDir, bucketName, objectKey string) {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodPut:
+		if query.Has("acl") {
+			object.PutObjectAclHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("tagging") {
+			object.PutObjectTaggingHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("retention") {
+			object.PutObjectRetentionHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("legal-hold") {
+			object.PutObjectLegalHoldHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("uploadId") && query.Has("partNumber") {
+			object.UploadPartHandler(w, r, dataDir, bucketName, objectKey)
+		} else if r.Header.Get("X-Amz-Copy-Source") != "" {
+			object.CopyObjectHandler(w, r, dataDir, bucketName, objectKey)
+		} else {
+			object.UploadObjectHandler(w, r, dataDir, bucketName, objectKey)
+		}
+	case http.MethodPost:
+		if query.Has("uploads") {
+			object.CreateMultipartUploadHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("uploadId") {
+			object.CompleteMultipartUploadHandler(w, r, dataDir, bucketName, objectKey)
+		} else {
+			s3error.WriteError(w, r, s3error.ErrMethodNotAllowed)
+		}
+	case http.MethodGet:
+		if query.Has("acl") {
+			object.GetObjectAclHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("tagging") {
+			object.GetObjectTaggingHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("retention") {
+			object.GetObjectRetentionHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("legal-hold") {
+			object.GetObjectLegalHoldHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("uploadId") {
+			object.ListPartsHandler(w, r, dataDir, bucketName, objectKey)
+		} else {
+			object.RetrieveObjectHandler(w, r, dataDir, bucketName, objectKey)
+		}
+	case http.MethodHead:
+		object.HeadObjectHandler(w, r, dataDir, bucketName, objectKey)
+	case http.MethodDelete:
+		if query.Has("tagging") {
+			object.DeleteObjectTaggingHandler(w, r, dataDir, bucketName, objectKey)
+		} else if query.Has("uploadId") {
+			object.AbortMultipartUploadHandler(w, r, dataDir, bucketName, objectKey)
+		} else {
+			object.DeleteObjectHandler(w, r, dataDir, bucketName, objectKey)
+		}
+	default:
+		s3error.WriteError(w, r, s3error.ErrMethodNotAllowed)
+	}
+}
+
+// bucketFromHost извлекает имя ведра из заголовка Host вида <bucket>.<baseDomain>.
+// Порт и регистр хоста не учитываются; сам baseDomain адресует сервис по пути.
+func bucketFromHost(host, baseDomain string) (string, bool) {
+	if baseDomain == "" {
+		return "", false
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	bucketName, found := strings.CutSuffix(host, "."+strings.ToLower(strings.Trim(baseDomain, ".")))
+	if !found || bucketName == "" {
+		return "", false
+	}
+	return bucketName, true
 }
 
 func ValidatePort(port string) (int, error) {
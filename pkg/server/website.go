@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"triple-s/pkg/bucket"
+	"triple-s/pkg/object"
+	"triple-s/pkg/s3error"
+)
+
+// SetupWebsite возвращает обработчик отдельного website-эндпоинта. Ведро задается хостом
+// <bucket>.<websiteDomain>, а если хост не совпадает — первым сегментом пути (/{bucket}/{path}).
+func SetupWebsite(dataDir, websiteDomain string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(s3error.RequestIDHeader, s3error.NewRequestID())
+
+		path, found := strings.CutPrefix(r.URL.Path, "/")
+		if !found {
+			s3error.WriteHTMLError(w, r, s3error.ErrInvalidURI)
+			return
+		}
+		if bucketName, ok := bucketFromHost(r.Host, websiteDomain); ok {
+			serveWebsite(w, r, dataDir, bucketName, path, "/")
+			return
+		}
+
+		bucketName, objectKey, found := strings.Cut(path, "/")
+		if bucketName != "" && !found {
+			// Без косой черты относительные ссылки страниц сайта указывали бы мимо ведра
+			http.Redirect(w, r, "/"+bucketName+"/", http.StatusFound)
+			return
+		}
+		serveWebsite(w, r, dataDir, bucketName, objectKey, "/"+bucketName+"/")
+	})
+}
+
+// serveWebsite отдает запрос к сайту ведра. CORS и доступ к каждому отдаваемому ключу
+// оцениваются так же, как для GET /{bucket}/{key}.
+func serveWebsite(w http.ResponseWriter, r *http.Request, dataDir, bucketName, objectKey, base string) {
+	if bucketName == "" {
+		s3error.WriteHTMLError(w, r, s3error.ErrNoSuchBucket)
+		return
+	}
+	// Имя ведра берется из хоста или пути и проверяется так же, как в SetupRoutes
+	if err := bucket.ValidateName(bucketName); err != nil {
+		s3error.WriteHTMLError(w, r, err)
+		return
+	}
+	if r.Method == http.MethodOptions {
+		object.CORSPreflightHandler(w, r, dataDir, bucketName)
+		return
+	}
+	object.SetCORSHeaders(w, r, dataDir, bucketName)
+
+	// Сайт отдает только текущие версии, поэтому параметры запроса при проверке доступа не учитываются
+	plain := r.Clone(r.Context())
+	plain.URL.RawQuery = ""
+	object.ServeWebsite(w, r, dataDir, bucketName, objectKey, base, func(objectKey string) error {
+		_, err := authorize(plain, dataDir, bucketName, objectKey)
+		return err
+	})
+}
@@ -0,0 +1,47 @@
+package sse
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// fingerprintSaltSize — размер соли отпечатка ключа клиента
+const fingerprintSaltSize = 16
+
+// Fingerprint возвращает соленый отпечаток ключа клиента вида "соль:HMAC-SHA256(соль, ключ)" в base64.
+// В отличие от MD5 ключа, который клиент передает в заголовке, по отпечатку нельзя
+// проверить ключ без соли, а одинаковые ключи дают разные отпечатки.
+func Fingerprint(key []byte) (string, error) {
+	salt := make([]byte, fingerprintSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("unable to generate key fingerprint: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(salt) + ":" + base64.StdEncoding.EncodeToString(fingerprintMAC(salt, key)), nil
+}
+
+// MatchFingerprint сообщает, что ключ соответствует отпечатку
+func MatchFingerprint(fingerprint string, key []byte) bool {
+	encodedSalt, encodedMAC, found := strings.Cut(fingerprint, ":")
+	if !found {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(encodedSalt)
+	if err != nil {
+		return false
+	}
+	mac, err := base64.StdEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(mac, fingerprintMAC(salt, key))
+}
+
+func fingerprintMAC(salt, key []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(key)
+	return mac.Sum(nil)
+}
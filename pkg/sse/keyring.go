@@ -0,0 +1,232 @@
+package sse
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// MasterKeyFileName — файл мастер-ключей в каталоге данных по умолчанию
+const MasterKeyFileName = "master.key"
+
+// Keyring хранит мастер-ключи, которыми оборачиваются ключи данных объектов.
+// Файл содержит строки "KeyID,ключ в base64"; действующим считается последний ключ.
+// Прежние ключи остаются в файле, пока ротация не переобернет все ключи данных.
+type Keyring struct {
+	path string
+	mu   sync.RWMutex
+	keys map[string][]byte
+	// order — идентификаторы ключей в порядке файла; последний действующий
+	order []string
+}
+
+// LoadKeyring читает мастер-ключи из файла. Если файла нет, создается новый ключ.
+func LoadKeyring(path string) (*Keyring, error) {
+	keyring := &Keyring{path: path, keys: make(map[string][]byte)}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		if _, err := keyring.Rotate(); err != nil {
+			return nil, err
+		}
+		return keyring, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error opening master key file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading master key file: %v", err)
+	}
+	for _, record := range records {
+		if len(record) < 2 || record[0] == "" || strings.Contains(record[0], ":") {
+			return nil, fmt.Errorf("malformed master key record")
+		}
+		key, err := base64.StdEncoding.DecodeString(record[1])
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("master key %s must be %d bytes in base64", record[0], KeySize)
+		}
+		keyring.keys[record[0]] = key
+		keyring.order = append(keyring.order, record[0])
+	}
+	if len(keyring.order) == 0 {
+		return nil, fmt.Errorf("master key file %s is empty", path)
+	}
+	return keyring, nil
+}
+
+// ActiveKeyID возвращает идентификатор действующего мастер-ключа
+func (k *Keyring) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.order[len(k.order)-1]
+}
+
+// GenerateDataKey создает ключ данных и возвращает его вместе с копией,
+// обернутой действующим мастер-ключом
+func (k *Keyring) GenerateDataKey() ([]byte, string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", fmt.Errorf("unable to generate data key: %v", err)
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	wrapped, err := k.wrap(k.order[len(k.order)-1], dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return dataKey, wrapped, nil
+}
+
+// Unwrap расшифровывает ключ данных, обернутый любым из мастер-ключей
+func (k *Keyring) Unwrap(wrapped string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.unwrap(wrapped)
+}
+
+// Rewrap переоборачивает ключ данных действующим мастер-ключом.
+// changed == false, если ключ уже обернут действующим мастер-ключом.
+func (k *Keyring) Rewrap(wrapped string) (string, bool, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	active := k.order[len(k.order)-1]
+	if keyID, _, _ := strings.Cut(wrapped, ":"); keyID == active {
+		return wrapped, false, nil
+	}
+	dataKey, err := k.unwrap(wrapped)
+	if err != nil {
+		return "", false, err
+	}
+	rewrapped, err := k.wrap(active, dataKey)
+	return rewrapped, err == nil, err
+}
+
+// Rotate создает новый мастер-ключ и делает его действующим. Прежние ключи
+// сохраняются до вызова Retire, чтобы уже обернутые ключи данных оставались доступны.
+func (k *Keyring) Rotate() (string, error) {
+	key := make([]byte, KeySize)
+	id := make([]byte, 8)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("unable to generate master key: %v", err)
+	}
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("unable to generate master key: %v", err)
+	}
+	keyID := hex.EncodeToString(id)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys := make(map[string][]byte, len(k.keys)+1)
+	for existing, value := range k.keys {
+		keys[existing] = value
+	}
+	keys[keyID] = key
+	order := append(append([]string(nil), k.order...), keyID)
+	if err := k.save(keys, order); err != nil {
+		return "", err
+	}
+	k.keys, k.order = keys, order
+	return keyID, nil
+}
+
+// Retire удаляет из файла все мастер-ключи, кроме действующего.
+// Вызывается после того, как все ключи данных переобернуты.
+func (k *Keyring) Retire() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	active := k.order[len(k.order)-1]
+	keys := map[string][]byte{active: k.keys[active]}
+	order := []string{active}
+	if err := k.save(keys, order); err != nil {
+		return err
+	}
+	k.keys, k.order = keys, order
+	return nil
+}
+
+// wrap шифрует ключ данных мастер-ключом keyID (AES-GCM) и возвращает "KeyID:base64(nonce|шифртекст)"
+func (k *Keyring) wrap(keyID string, dataKey []byte) (string, error) {
+	aead, err := newGCM(k.keys[keyID])
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("unable to wrap data key: %v", err)
+	}
+	sealed := aead.Seal(nonce, nonce, dataKey, []byte(keyID))
+	return keyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// unwrap расшифровывает ключ данных; вызывающий держит k.mu
+func (k *Keyring) unwrap(wrapped string) ([]byte, error) {
+	keyID, encoded, found := strings.Cut(wrapped, ":")
+	masterKey, ok := k.keys[keyID]
+	if !found || !ok {
+		return nil, fmt.Errorf("master key %q of a data key is not available", keyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed wrapped data key: %v", err)
+	}
+	aead, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed wrapped data key")
+	}
+	dataKey, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to unwrap data key with master key %s: %v", keyID, err)
+	}
+	return dataKey, nil
+}
+
+// save атомарно перезаписывает файл мастер-ключей
+func (k *Keyring) save(keys map[string][]byte, order []string) error {
+	if err := os.MkdirAll(filepath.Dir(k.path), 0o700); err != nil {
+		return fmt.Errorf("error creating master key directory: %v", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(k.path), "."+filepath.Base(k.path)+"-*")
+	if err != nil {
+		return fmt.Errorf("error writing master key file: %v", err)
+	}
+	writer := csv.NewWriter(file)
+	for _, keyID := range order {
+		writer.Write([]string{keyID, base64.StdEncoding.EncodeToString(keys[keyID])})
+	}
+	writer.Flush()
+	err = writer.Error()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), k.path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("error writing master key file: %v", err)
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,125 @@
+package sse
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/url"
+)
+
+const (
+	// AlgorithmAES256 — значение x-amz-server-side-encryption для шифрования ключами хранилища
+	AlgorithmAES256 = "AES256"
+	// AlgorithmKMS — значение x-amz-server-side-encryption для шифрования ключами службы ключей
+	AlgorithmKMS = "aws:kms"
+	// KeySize — размер ключей данных и мастер-ключей (AES-256)
+	KeySize = 32
+)
+
+// Envelope описывает шифрование версии объекта: алгоритм, ключ данных, обернутый
+// мастер-ключом, и начальный вектор AES-CTR. Пустой Envelope означает открытые данные.
+// У данных, зашифрованных ключом клиента (SSE-C), вместо обернутого ключа хранится
+// только соленый отпечаток ключа. У aws:kms ключ данных зашифрован ключом KMSKeyID
+// службы ключей, а не мастер-ключом.
+type Envelope struct {
+	Algorithm      string
+	WrappedKey     string
+	KeyFingerprint string
+	KMSKeyID       string
+	IV             []byte
+}
+
+// Encrypted сообщает, что данные зашифрованы
+func (e Envelope) Encrypted() bool {
+	return e.Algorithm != ""
+}
+
+// CustomerKey сообщает, что данные зашифрованы ключом клиента
+func (e Envelope) CustomerKey() bool {
+	return e.KeyFingerprint != ""
+}
+
+// MasterKeyWrapped сообщает, что ключ данных обернут мастер-ключом хранилища
+// и переоборачивается при его ротации
+func (e Envelope) MasterKeyWrapped() bool {
+	return e.Encrypted() && !e.CustomerKey() && e.KMSKeyID == ""
+}
+
+// Encode сериализует Envelope в строку вида alg=AES256&key=...&iv=... для хранения в CSV;
+// для SSE-C вместо key записывается отпечаток kfp
+func (e Envelope) Encode() string {
+	if !e.Encrypted() {
+		return ""
+	}
+	values := url.Values{"alg": {e.Algorithm}}
+	if e.CustomerKey() {
+		values.Set("kfp", e.KeyFingerprint)
+	} else {
+		values.Set("key", e.WrappedKey)
+	}
+	if e.KMSKeyID != "" {
+		values.Set("kms", e.KMSKeyID)
+	}
+	if e.IV != nil {
+		values.Set("iv", base64.StdEncoding.EncodeToString(e.IV))
+	}
+	return values.Encode()
+}
+
+// DecodeEnvelope разбирает строку, созданную Encode
+func DecodeEnvelope(encoded string) (Envelope, error) {
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		return Envelope{}, err
+	}
+	envelope := Envelope{Algorithm: values.Get("alg"), WrappedKey: values.Get("key"), KeyFingerprint: values.Get("kfp"), KMSKeyID: values.Get("kms")}
+	if iv := values.Get("iv"); iv != "" {
+		if envelope.IV, err = base64.StdEncoding.DecodeString(iv); err != nil || len(envelope.IV) != aes.BlockSize {
+			return Envelope{}, fmt.Errorf("malformed encryption IV")
+		}
+	}
+	return envelope, nil
+}
+
+// NewIV возвращает случайный начальный вектор AES-CTR
+func NewIV() ([]byte, error) {
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("unable to generate IV: %v", err)
+	}
+	return iv, nil
+}
+
+// NewStream возвращает поток AES-256-CTR, начинающийся с байта offset открытых данных.
+// CTR сохраняет размер данных и позволяет расшифровать любой диапазон без чтения предыдущих байт,
+// поэтому GET с Range читает с диска только запрошенную часть. Шифрование и расшифровка совпадают.
+func NewStream(key, iv []byte, offset int64) (cipher.Stream, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %v", err)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid IV length %d", len(iv))
+	}
+
+	// Счетчик блока, содержащего offset: IV + offset/16 по модулю 2^128
+	counter := new(big.Int).SetBytes(iv)
+	counter.Add(counter, big.NewInt(offset/aes.BlockSize))
+	blockIV := make([]byte, aes.BlockSize)
+	counterBytes := counter.Bytes()
+	if len(counterBytes) > aes.BlockSize {
+		counterBytes = counterBytes[len(counterBytes)-aes.BlockSize:]
+	}
+	copy(blockIV[aes.BlockSize-len(counterBytes):], counterBytes)
+
+	stream := cipher.NewCTR(block, blockIV)
+	// Пропуск начала блока до offset
+	if skip := offset % aes.BlockSize; skip > 0 {
+		discard := make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	return stream, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"triple-s/pkg/auth"
+)
+
+// runPresign печатает подписанную ссылку на объект: triple-s presign [options]
+func runPresign(args []string) {
+	flags := flag.NewFlagSet("presign", flag.ExitOnError)
+	dir := flags.String("dir", "data", "Path to the directory with credentials.csv")
+	endpoint := flags.String("endpoint", "http://localhost:8080", "Server URL")
+	method := flags.String("method", "GET", "HTTP method the link is valid for (GET or PUT)")
+	bucketName := flags.String("bucket", "", "Bucket name")
+	objectKey := flags.String("key", "", "Object key")
+	expires := flags.Duration("expires", time.Hour, "Link lifetime, at most 168h")
+	accessKey := flags.String("access-key", "", "Access key to sign with (defaults to the only key in the store)")
+	region := flags.String("region", "us-east-1", "Region used in the credential scope")
+	flags.Parse(args)
+
+	if *bucketName == "" || *objectKey == "" {
+		fmt.Fprintln(os.Stderr, "presign: -bucket and -key are required")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	store, err := auth.LoadStore(*dir)
+	if err != nil {
+		log.Fatalf("error loading credentials: %v", err)
+	}
+	if *accessKey == "" {
+		keys := store.AccessKeys()
+		if len(keys) != 1 {
+			log.Fatalf("found %d access keys in %s, choose one with -access-key", len(keys), *dir)
+		}
+		*accessKey = keys[0]
+	}
+	secretKey, ok := store.Lookup(*accessKey)
+	if !ok {
+		log.Fatalf("access key %s not found", *accessKey)
+	}
+
+	link, err := auth.Presign(auth.PresignOptions{
+		Endpoint:  *endpoint,
+		Method:    *method,
+		Bucket:    *bucketName,
+		Key:       *objectKey,
+		AccessKey: *accessKey,
+		SecretKey: secretKey,
+		Region:    *region,
+		Expires:   *expires,
+	}, time.Now())
+	if err != nil {
+		log.Fatalf("error creating presigned URL: %v", err)
+	}
+	fmt.Println(link)
+}